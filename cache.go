@@ -0,0 +1,145 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// cacheVersion is the version of the cache format.  Caches of version 0 are
+// a bare JSON array of the matches with the working directory as last row.
+const cacheVersion = 1
+
+// cacheData is the format of the cache.
+type cacheData struct {
+	Version  int        `json:"version"`
+	WorkDir  string     `json:"workDir"`
+	Args     []string   `json:"args"`
+	Backend  string     `json:"backend"`
+	ExitCode int        `json:"exitCode"`
+	Created  time.Time  `json:"created"`
+	Matches  [][]string `json:"matches"`
+
+	// Files are the stamps of the matched files at the time of the search.
+	Files map[string]fileStamp `json:"files,omitempty"`
+}
+
+// cacheData returns the current matches along with the metadata of the
+// search.
+func (v *vgrep) cacheData() *cacheData {
+	matches := v.matches
+	if matches == nil {
+		matches = [][]string{}
+	}
+	return &cacheData{
+		Version:  cacheVersion,
+		WorkDir:  v.workDir,
+		Args:     v.query,
+		Backend:  v.searchBackend,
+		ExitCode: v.searchExitCode,
+		Created:  v.searchTime,
+		Matches:  matches,
+		Files:    v.fileStamps,
+	}
+}
+
+// encodeCache encodes the current matches along with the metadata of the
+// search.
+func (v *vgrep) encodeCache() ([]byte, error) {
+	return json.Marshal(v.cacheData())
+}
+
+// decodeCache decodes data and migrates caches of previous versions.  Since
+// caches of version 0 do not record the time of the search, it is set to
+// modTime.
+func decodeCache(data []byte, modTime time.Time) (*cacheData, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var matches [][]string
+		if err := json.Unmarshal(data, &matches); err != nil {
+			return nil, err
+		}
+		logrus.Debug("migrating cache of version 0")
+		cache := &cacheData{Version: cacheVersion, Created: modTime}
+		if length := len(matches); length > 0 {
+			cache.WorkDir = matches[length-1][0]
+			matches = matches[:length-1]
+		}
+		cache.Matches = matches
+		return cache, nil
+	}
+
+	cache := &cacheData{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	if cache.Version > cacheVersion {
+		return nil, fmt.Errorf("unsupported cache version %d (expected %d)", cache.Version, cacheVersion)
+	}
+	return cache, nil
+}
+
+// useCache makes the matches of cache the current ones.
+func (v *vgrep) useCache(cache *cacheData) {
+	v.matches = cache.Matches
+	v.workDir = cache.WorkDir
+	v.query = cache.Args
+	v.searchBackend = cache.Backend
+	v.searchExitCode = cache.ExitCode
+	v.searchTime = cache.Created
+	v.fileStamps = cache.Files
+}
+
+// cacheHeader returns a line describing the search of the current matches
+// (e.g., "results of rg: `-i foo` in /src/x, 3h ago").
+func (v *vgrep) cacheHeader() string {
+	header := "results"
+	if v.searchBackend != "" {
+		header += " of " + searchLabel(v.searchBackend, v.query)
+	}
+	header += " in " + v.workDir
+	if !v.searchTime.IsZero() {
+		header += ", " + formatAge(time.Since(v.searchTime))
+	}
+	if v.searchExitCode != 0 {
+		header += fmt.Sprintf(" (exit code %d)", v.searchExitCode)
+	}
+	return header
+}
+
+// printCacheHeader prints the header describing the current matches unless
+// disabled or only files are listed.  The header is written to stderr if
+// stdout is neither a terminal nor the interactive shell, so that piping the
+// results into other tools is not affected.
+func (v *vgrep) printCacheHeader() {
+	if v.NoHeader || v.FilesOnly {
+		return
+	}
+	out := os.Stdout
+	if !v.Interactive && !term.IsTerminal(int(os.Stdout.Fd())) {
+		out = os.Stderr
+	}
+	fmt.Fprintln(out, v.cacheHeader())
+}
+
+// formatAge returns a short human-readable description of age (e.g., "3h
+// ago").
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
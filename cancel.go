@@ -0,0 +1,61 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// startSearch allows for cancelling the following search via
+// interruptSearch.
+func (v *vgrep) startSearch() {
+	v.cancelMutex.Lock()
+	defer v.cancelMutex.Unlock()
+	v.cancel = make(chan struct{})
+}
+
+// stopSearch marks the end of the search and returns true if it has been
+// cancelled.
+func (v *vgrep) stopSearch() bool {
+	cancelled := v.searchCancelled()
+	v.cancelMutex.Lock()
+	defer v.cancelMutex.Unlock()
+	v.cancel = nil
+	return cancelled
+}
+
+// interruptSearch cancels the running search.  It is a NOP if no search is
+// running.
+func (v *vgrep) interruptSearch() {
+	v.cancelMutex.Lock()
+	defer v.cancelMutex.Unlock()
+	if v.cancel == nil {
+		return
+	}
+	select {
+	case <-v.cancel:
+	default:
+		logrus.Debug("cancelling search")
+		close(v.cancel)
+	}
+}
+
+// cancelled returns a channel that is closed once the running search is
+// cancelled.  The channel is nil if no search is running.
+func (v *vgrep) cancelled() <-chan struct{} {
+	v.cancelMutex.Lock()
+	defer v.cancelMutex.Unlock()
+	return v.cancel
+}
+
+// searchCancelled returns true if the running search has been cancelled.
+func (v *vgrep) searchCancelled() bool {
+	select {
+	case <-v.cancelled():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// commandEdit writes the matches at the specified indices to a temporary file
+// in the format "index<TAB>file:line<TAB>content" and opens it in the editor.
+// Once the editor exits, all changed content lines are written back to their
+// files.  Lines that changed since the search are not touched.
+func (v *vgrep) commandEdit(indices []int) bool {
+	var err error
+
+	indices, err = v.checkIndices(indices)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return false
+	}
+
+	tmp, err := os.CreateTemp("", "vgrep-edit-*.txt")
+	if err != nil {
+		fmt.Printf("error creating temporary file: %v\n", err)
+		return false
+	}
+	defer os.Remove(tmp.Name())
+
+	original := make(map[int]string)
+	var buffer strings.Builder
+	for _, idx := range indices {
+		content := lineContent(ansi.RemoveANSI(v.matches[idx][3]))
+		original[idx] = content
+		fmt.Fprintf(&buffer, "%d\t%s:%s\t%s\n", idx, v.matches[idx][1], v.matches[idx][2], content)
+	}
+	if _, err := tmp.WriteString(buffer.String()); err != nil {
+		tmp.Close()
+		fmt.Printf("error writing temporary file: %v\n", err)
+		return false
+	}
+	if err := tmp.Close(); err != nil {
+		fmt.Printf("error writing temporary file: %v\n", err)
+		return false
+	}
+
+	editor := append(v.getEditor(), tmp.Name())
+	logrus.Debugf("editing %d matches via: %s", len(indices), editor)
+	cmd := exec.Command(editor[0], editor[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("couldn't edit matches: %v\n", err)
+		return false
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		fmt.Printf("error reading temporary file: %v\n", err)
+		return false
+	}
+
+	// Collect the changed lines.  Lines that have been removed from the
+	// buffer remain unchanged.
+	changes := make(map[int]string)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			fmt.Printf("ignoring malformed line %d: %q\n", n+1, line)
+			continue
+		}
+		idx, err := strconv.Atoi(fields[0])
+		content, selected := original[idx]
+		if err != nil || !selected || fields[1] != v.matches[idx][1]+":"+v.matches[idx][2] {
+			fmt.Printf("ignoring line %d: unknown match %q\n", n+1, fields[0]+"\t"+fields[1])
+			continue
+		}
+		if fields[2] != content {
+			changes[idx] = fields[2]
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Println("no lines changed")
+		return false
+	}
+
+	changed := make([]int, 0, len(changes))
+	for idx := range changes {
+		changed = append(changed, idx)
+	}
+	sort.Ints(changed)
+	files, _ := v.prepareEdits(changed, func(edit *lineEdit) bool {
+		edit.new = changes[edit.index]
+		return edit.new != edit.old
+	})
+	numLines := v.writeEdits(files)
+	fmt.Printf("changed %d line(s) in %d file(s)\n", numLines, len(files))
+
+	return false
+}
+
+// lineEdit is the change of a single matched line.
+type lineEdit struct {
+	index int     // index of the match
+	line  int     // line number in the file
+	old   string  // content of the line
+	new   string  // content of the line after the change
+	spans [][]int // byte offsets of the changed text in new, if known
+}
+
+// fileEdits are the changes of a single file.
+type fileEdits struct {
+	path  string      // path to the file
+	name  string      // path as listed in the matches
+	lines []string    // lines of the file including line endings
+	edits []*lineEdit // sorted by line number
+}
+
+// lineContent returns line without its line ending.
+func lineContent(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// prepareEdits reads the files of the matches at the specified indices and
+// calls change for each matched line, which sets the new content and returns
+// false if the line does not change.  Lines that changed since the search
+// are refused.  prepareEdits returns the edits grouped by file along with the
+// number of changed lines.
+func (v *vgrep) prepareEdits(indices []int, change func(edit *lineEdit) bool) ([]*fileEdits, int) {
+	// Group the matches by file while preserving the order.
+	var files []*fileEdits
+	byPath := make(map[string]*fileEdits)
+	for _, idx := range indices {
+		path, line, err := v.fileLocation(idx)
+		if err != nil {
+			logrus.Warn(err.Error())
+			continue
+		}
+		file, exists := byPath[path]
+		if !exists {
+			file = &fileEdits{path: path, name: v.matches[idx][1]}
+			byPath[path] = file
+			files = append(files, file)
+		}
+		file.edits = append(file.edits, &lineEdit{index: idx, line: line})
+	}
+
+	var prepared []*fileEdits
+	changed := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			fmt.Printf("error reading file %q: %v\n", file.path, err)
+			continue
+		}
+		file.lines = strings.SplitAfter(string(data), "\n")
+
+		var edits []*lineEdit
+		seen := make(map[int]bool)
+		for _, edit := range file.edits {
+			if seen[edit.line] {
+				continue
+			}
+			seen[edit.line] = true
+			if edit.line < 1 || edit.line > len(file.lines) {
+				fmt.Printf("refusing to change %s:%d: line does not exist anymore\n", file.name, edit.line)
+				continue
+			}
+			edit.old = lineContent(file.lines[edit.line-1])
+			if edit.old != lineContent(ansi.RemoveANSI(v.matches[edit.index][3])) {
+				fmt.Printf("refusing to change %s:%d: line changed since the search\n", file.name, edit.line)
+				continue
+			}
+			if change(edit) {
+				edits = append(edits, edit)
+			}
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		sort.Slice(edits, func(i, j int) bool { return edits[i].line < edits[j].line })
+		file.edits = edits
+		prepared = append(prepared, file)
+		changed += len(edits)
+	}
+
+	return prepared, changed
+}
+
+// writeEdits atomically writes the edits to the files and updates the
+// matches accordingly.  Files that changed since they have been read by
+// prepareEdits are not touched.  It returns the number of changed lines.
+func (v *vgrep) writeEdits(files []*fileEdits) int {
+	changed := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			fmt.Printf("error reading file %q: %v\n", file.path, err)
+			continue
+		}
+		if string(data) != strings.Join(file.lines, "") {
+			fmt.Printf("refusing to change %s: file changed in the meantime\n", file.name)
+			continue
+		}
+		for _, edit := range file.edits {
+			file.lines[edit.line-1] = edit.new + file.lines[edit.line-1][len(edit.old):]
+		}
+		if err := writeFileContents(file.path, []byte(strings.Join(file.lines, ""))); err != nil {
+			fmt.Printf("error writing file %q: %v\n", file.path, err)
+			continue
+		}
+		for _, edit := range file.edits {
+			m := v.matches[edit.index]
+			m[3] = highlight(edit.new, edit.spans)
+			if len(m) >= 6 {
+				m[4], m[5] = "", formatSpans(edit.spans)
+				if len(edit.spans) > 0 {
+					m[4] = strconv.Itoa(edit.spans[0][0] + 1)
+				}
+			}
+		}
+		if stamp, err := v.statFile(file.name); err == nil && v.fileStamps != nil {
+			v.fileStamps[file.name] = stamp
+		}
+		changed += len(file.edits)
+	}
+
+	// Update the cache to not consider the changed lines to be stale.
+	if changed > 0 {
+		if err := v.updateCache(); err != nil {
+			logrus.Debugf("error writing cache: %v", err)
+		}
+	}
+	return changed
+}
+
+// writeFileContents atomically replaces the contents of the existing file at
+// path with data.  Symlinks are resolved, so the file they point to is
+// replaced, and the new file gets the mode, owner and group of the old one.
+// Files with several hardlinks are written in place since replacing them
+// would break the links.  Files are written in place as well if the user
+// cannot create a file in the directory or give it the owner and group of the
+// old one (e.g., a group-writable file of another user).
+func writeFileContents(path string, data []byte) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if hardlinks(info) > 1 {
+		return writeFileInPlace(target, data)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+"-*")
+	if err != nil {
+		logrus.Debugf("writing %s in place: %v", target, err)
+		return writeFileInPlace(target, data)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := chownAs(file, info); err != nil {
+		logrus.Debugf("writing %s in place: %v", target, err)
+		return writeFileInPlace(target, data)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	if err := file.Chmod(info.Mode()); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), target)
+}
+
+// writeFileInPlace overwrites the contents of the existing file at path with
+// data.
+func writeFileInPlace(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"os"
+	"syscall"
+)
+
+// hardlinks returns the number of hardlinks of the file described by info.
+func hardlinks(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// chownAs changes the owner and group of file to the ones of the file
+// described by info.
+func chownAs(file *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return file.Chown(int(stat.Uid), int(stat.Gid))
+}
@@ -0,0 +1,19 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"os"
+)
+
+// hardlinks returns 1 on Windows.
+func hardlinks(info os.FileInfo) uint64 {
+	return 1
+}
+
+// chownAs is a NOP on Windows.
+func chownAs(file *os.File, info os.FileInfo) error {
+	return nil
+}
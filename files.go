@@ -0,0 +1,96 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// fileStats are the statistics of the matches in a file.
+type fileStats struct {
+	name    string
+	index   int          // index of the first match
+	matches int          // number of matches
+	lines   map[int]bool // matched lines
+	first   int          // first matched line
+	last    int          // last matched line
+}
+
+// commandListFiles prints statistics about how many matches occur in which
+// files in the search.  If top is greater than 0, only the top files with
+// the most matches are printed.  The index of the first match of each file
+// allows for opening all matches of the file via the "@" selector.
+func (v *vgrep) commandListFiles(indices []int, top int) bool {
+	var err error
+
+	if indices, err = v.checkIndices(indices); err != nil {
+		fmt.Printf("%v\n", err)
+		return false
+	}
+
+	var files []*fileStats
+	stats := make(map[string]*fileStats)
+	for _, idx := range indices {
+		m := v.matches[idx]
+		line, _ := strconv.Atoi(m[2])
+		s, exists := stats[m[1]]
+		if !exists {
+			s = &fileStats{name: m[1], index: idx, lines: make(map[int]bool), first: line, last: line}
+			stats[m[1]] = s
+			files = append(files, s)
+		}
+		s.matches++
+		s.lines[line] = true
+		if line < s.first {
+			s.first = line
+		}
+		if line > s.last {
+			s.last = line
+		}
+	}
+
+	byCount := v.FilesSort == "count" || top > 0
+	sort.Slice(files, func(i, j int) bool {
+		if byCount && files[i].matches != files[j].matches {
+			return files[i].matches > files[j].matches
+		}
+		return files[i].name < files[j].name
+	})
+	if top > 0 && top < len(files) {
+		files = files[:top]
+	}
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, []string{"Index", "Matches", "Lines", "First", "Last", "File"})
+	}
+	for _, s := range files {
+		toPrint = append(toPrint, []string{
+			"@" + strconv.Itoa(s.index),
+			strconv.Itoa(s.matches),
+			strconv.Itoa(len(s.lines)),
+			strconv.Itoa(s.first),
+			strconv.Itoa(s.last),
+			s.name,
+		})
+	}
+
+	cw := colwriter.New(6)
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.MAGENTA, ansi.DEFAULT, ansi.DEFAULT, ansi.DEFAULT, ansi.GREEN}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadLeft, colwriter.PadLeft, colwriter.PadLeft, colwriter.PadLeft, colwriter.PadNone}
+	cw.UseLess = !v.NoLess
+
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+
+	return false
+}
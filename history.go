@@ -0,0 +1,263 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// defaultHistorySize is the number of searches kept in the history unless
+// specified otherwise via the VGREP_HISTORY_SIZE environment variable.
+const defaultHistorySize = 10
+
+// historyEntry describes a search in the history.  The results of each
+// search are stored in a separate file named after the entry's ID.
+type historyEntry struct {
+	ID      string    `json:"id"`
+	Args    []string  `json:"args"`
+	Backend string    `json:"backend"`
+	WorkDir string    `json:"workDir"`
+	Time    time.Time `json:"time"`
+	Matches int       `json:"matches"`
+}
+
+// searchCommand returns the command line of a search with the specified
+// backend and args (e.g., "rg -i foo").
+func searchCommand(backend string, args []string) string {
+	return strings.Join(append([]string{backend}, quoteArgs(args)...), " ")
+}
+
+// searchLabel describes a search with the specified backend and args in
+// headers (e.g., "rg: `-i foo`").  Unlike searchCommand, it does not pretend
+// to be a command line, which would not be runnable for the built-in search.
+func searchLabel(backend string, args []string) string {
+	return backend + ": `" + strings.Join(quoteArgs(args), " ") + "`"
+}
+
+// quoteArgs quotes the args that would otherwise be ambiguous when joined by
+// spaces.
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return quoted
+}
+
+// historySize returns the number of searches to keep in the history.
+func historySize() int {
+	size, err := strconv.Atoi(os.Getenv("VGREP_HISTORY_SIZE"))
+	if err != nil || size < 1 {
+		return defaultHistorySize
+	}
+	return size
+}
+
+// historyPath returns the path to the directory of the search history.
+func (v *vgrep) historyPath() (string, error) {
+	cache, err := v.cachePath()
+	if err != nil {
+		return "", err
+	}
+	history := filepath.Join(filepath.Dir(cache), "vgrep-history")
+	if err := os.MkdirAll(history, 0700); err != nil {
+		return "", err
+	}
+	return history, nil
+}
+
+// readHistory returns the entries of the search history in the specified
+// directory, the most recent one first.  The caller must hold the lock.
+func readHistory(dir string) ([]historyEntry, error) {
+	var entries []historyEntry
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing search history: %v", err)
+	}
+	return entries, nil
+}
+
+// pushHistory adds the results of the current search, encoded in data, to
+// the search history and removes the oldest searches exceeding the size of
+// the history.  The caller must hold the lock.
+func (v *vgrep) pushHistory(data []byte) error {
+	dir, err := v.historyPath()
+	if err != nil {
+		return err
+	}
+	entries, err := readHistory(dir)
+	if err != nil {
+		logrus.Warnf("resetting search history: %v", err)
+		entries = nil
+	}
+
+	now := time.Now()
+	entry := historyEntry{
+		ID:      strconv.FormatInt(now.UnixNano(), 10),
+		Args:    v.query,
+		Backend: v.searchBackend,
+		WorkDir: v.workDir,
+		Time:    now,
+		Matches: len(v.matches),
+	}
+	if err := writeFileAtomic(filepath.Join(dir, entry.ID), data, 0644); err != nil {
+		return err
+	}
+	entries = append([]historyEntry{entry}, entries...)
+
+	if size := historySize(); len(entries) > size {
+		for _, old := range entries[size:] {
+			logrus.Debugf("removing search %s from history", old.ID)
+			if err := os.Remove(filepath.Join(dir, old.ID)); err != nil && !os.IsNotExist(err) {
+				logrus.Debugf("error removing search from history: %v", err)
+			}
+		}
+		entries = entries[:size]
+	}
+
+	index, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "index.json"), index, 0644)
+}
+
+// loadHistory loads the results of the search at the specified index of the
+// history and makes them the current results.
+func (v *vgrep) loadHistory(index int) error {
+	logrus.Debugf("loadHistory(%d)", index)
+
+	cache, err := v.cachePath()
+	if err != nil {
+		return fmt.Errorf("error getting cache path: %v", err)
+	}
+	dir, err := v.historyPath()
+	if err != nil {
+		return fmt.Errorf("error getting history path: %v", err)
+	}
+
+	if err := v.acquireLock(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := v.lock.Unlock(); err != nil {
+			panic(fmt.Sprintf("Error releasing lock file: %v", err))
+		}
+	}()
+
+	entries, err := readHistory(dir)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index > len(entries)-1 {
+		if len(entries) == 0 {
+			return errors.New("search history is empty")
+		}
+		return fmt.Errorf("index %d out of range (%d, %d)", index, 0, len(entries)-1)
+	}
+
+	entry := entries[index]
+	data, err := os.ReadFile(filepath.Join(dir, entry.ID))
+	if err != nil {
+		return err
+	}
+	search, err := decodeCache(data, entry.Time)
+	if err != nil {
+		return fmt.Errorf("error parsing search %d of the history: %v", index, err)
+	}
+	if search.Backend == "" {
+		// Migrated from version 0.
+		search.Args, search.Backend = entry.Args, entry.Backend
+	}
+	v.useCache(search)
+
+	data, err = v.encodeCache()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cache, data, 0644)
+}
+
+// commandHistory prints the searches in the history, the most recent one
+// first.
+func (v *vgrep) commandHistory() bool {
+	dir, err := v.historyPath()
+	if err != nil {
+		fmt.Printf("error getting history path: %v\n", err)
+		return false
+	}
+
+	if err := v.acquireLock(); err != nil {
+		fmt.Printf("error acquiring lock file: %v\n", err)
+		return false
+	}
+	entries, err := readHistory(dir)
+	if err := v.lock.Unlock(); err != nil {
+		panic(fmt.Sprintf("Error releasing lock file: %v", err))
+	}
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, []string{"Index", "Matches", "Time", "Directory", "Query"})
+	}
+	for i, entry := range entries {
+		toPrint = append(toPrint, []string{
+			strconv.Itoa(i),
+			strconv.Itoa(entry.Matches),
+			entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.WorkDir,
+			searchCommand(entry.Backend, entry.Args),
+		})
+	}
+
+	cw := colwriter.New(5)
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.MAGENTA, ansi.GREEN, ansi.BLUE, ansi.DEFAULT}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadLeft, colwriter.PadRight, colwriter.PadRight, colwriter.PadNone}
+	cw.UseLess = !v.NoLess
+
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+
+	return false
+}
+
+// commandLoad loads the search at the specified index of the history and
+// prints its results.
+func (v *vgrep) commandLoad(index int) bool {
+	if err := v.loadHistory(index); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	v.printCacheHeader()
+	if len(v.matches) > 0 {
+		v.commandPrintMatches([]int{})
+	}
+	return false
+}
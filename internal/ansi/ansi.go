@@ -71,6 +71,43 @@ func RemoveANSI(str string) string {
 	return ansiReg.ReplaceAllString(str, "")
 }
 
+// Spans removes all ANSI codes from str and returns the byte offsets of the
+// colored text in the resulting string.
+func Spans(str string) (string, [][]int) {
+	var (
+		plain []byte
+		spans [][]int
+		start = -1
+		last  = 0
+	)
+
+	for _, loc := range ansiReg.FindAllStringIndex(str, -1) {
+		plain = append(plain, str[last:loc[0]]...)
+		last = loc[1]
+
+		code := str[loc[0]:loc[1]]
+		if code[len(code)-1] != 'm' {
+			continue
+		}
+		params := code[2 : len(code)-1]
+		switch {
+		case params == "" || params == "0":
+			if start >= 0 && start < len(plain) {
+				spans = append(spans, []int{start, len(plain)})
+			}
+			start = -1
+		case start < 0:
+			start = len(plain)
+		}
+	}
+	plain = append(plain, str[last:]...)
+	if start >= 0 && start < len(plain) {
+		spans = append(spans, []int{start, len(plain)})
+	}
+
+	return string(plain), spans
+}
+
 // ClearLine clears all characters from the cursor position to the end of the
 // line (including the character at the cursor position).
 func ClearLine() {
@@ -36,6 +36,7 @@ type ColWriter struct {
 	pipe    io.WriteCloser // in case we use less(1)
 	cmd     *exec.Cmd      // required for cmd.Wait() for less(1)
 	opened  bool           // indicates if ColWriter is opened/closed
+	written int            // number of rows written via Append
 }
 
 // New returns a default ColWriter of size numColumns.
@@ -127,42 +128,112 @@ func (cw *ColWriter) Write(rows [][]string) {
 	if len(rows) == 0 {
 		return
 	}
-	max := len(rows[0]) - 1
 
 	if cw.Headers {
-		for i, str := range rows[0] {
-			out := cw.Padding[i](str, cw.Size[i], " ")
-			out = ansi.Underline(out)
-			out = ansi.Color(out, cw.Colors[i], true)
-			if i < max {
-				out += " "
-			} else {
-				out += "\n"
-			}
-			fmt.Fprintf(cw.writer, "%s", out)
-		}
+		cw.writeHeader(rows[0], "")
 		rows = rows[1:]
 	}
 	for lineNum, row := range rows {
-		var bright bool
-		if lineNum%2 == 0 {
-			bright = true
+		cw.writeRow(row, lineNum%2 == 0, "")
+	}
+}
+
+// Section is a block of rows preceded by a header line.
+type Section struct {
+	Header string     // written as is in a line of its own
+	Rows   [][]string // rows of the section
+}
+
+// sectionIndent is the indentation of the rows of sections.
+const sectionIndent = "  "
+
+// WriteSections writes the sections to cw's pipe.  Each section starts with
+// its header followed by its rows, which are indented and aligned with the
+// rows of all other sections.  Sections are separated by an empty line.  If
+// cw.Headers is set, header is written above the first section.
+func (cw *ColWriter) WriteSections(header []string, sections []Section) {
+	if !cw.opened {
+		panic("WriteSections() on unopened ColWriter\n")
+	}
+	if cw.Headers && header != nil {
+		cw.ComputeSize([][]string{header})
+	}
+	for _, section := range sections {
+		cw.ComputeSize(section.Rows)
+	}
+	if cw.Headers && header != nil {
+		cw.writeHeader(header, sectionIndent)
+	}
+	lineNum := 0
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintf(cw.writer, "\n")
+		}
+		fmt.Fprintf(cw.writer, "%s\n", section.Header)
+		for _, row := range section.Rows {
+			cw.writeRow(row, lineNum%2 == 0, sectionIndent)
+			lineNum++
+		}
+	}
+}
+
+// Append writes rows like Write but without headers and continues to
+// alternate colors from previous calls to Append.  It allows for writing rows
+// as soon as they are available.  Since column sizes may grow with later
+// rows, they are not necessarily aligned with previously written ones.
+func (cw *ColWriter) Append(rows [][]string) {
+	if !cw.opened {
+		panic("Append() on unopened ColWriter\n")
+	}
+	cw.ComputeSize(rows)
+	for _, row := range rows {
+		cw.writeRow(row, cw.written%2 == 0, "")
+		cw.written++
+	}
+}
+
+// Flush writes all buffered data to cw's pipe.
+func (cw *ColWriter) Flush() {
+	if !cw.opened {
+		panic("Flush() on unopened ColWriter\n")
+	}
+	cw.writer.Flush()
+}
+
+// writeHeader writes row underlined as a header prefixed with indent.
+func (cw *ColWriter) writeHeader(row []string, indent string) {
+	max := len(row) - 1
+	fmt.Fprintf(cw.writer, "%s", indent)
+	for i, str := range row {
+		out := cw.Padding[i](str, cw.Size[i], " ")
+		out = ansi.Underline(out)
+		out = ansi.Color(out, cw.Colors[i], true)
+		if i < max {
+			out += " "
 		} else {
-			bright = false
+			out += "\n"
+		}
+		fmt.Fprintf(cw.writer, "%s", out)
+	}
+}
+
+// writeRow writes a single row prefixed with indent.  Bright rows are written
+// in bright colors.
+func (cw *ColWriter) writeRow(row []string, bright bool, indent string) {
+	max := len(row) - 1
+	fmt.Fprintf(cw.writer, "%s", indent)
+	for i, str := range row {
+		if cw.Trim[i] {
+			str = strings.TrimSpace(str)
 		}
-		for i, str := range row {
-			if cw.Trim[i] {
-				str = strings.TrimSpace(str)
-			}
-			out := cw.Padding[i](str, cw.Size[i], " ")
-			out = ansi.Color(out, cw.Colors[i], bright)
-			if i < max {
-				out += " "
-			} else {
-				out += "\n"
-			}
-			fmt.Fprintf(cw.writer, "%s", out)
+		out := cw.Padding[i](str, cw.Size[i], " ")
+		out = ansi.Color(out, cw.Colors[i], bright)
+		if i < max {
+			out += " "
+		} else {
+			out += "\n"
 		}
+		fmt.Fprintf(cw.writer, "%s", out)
 	}
 }
 
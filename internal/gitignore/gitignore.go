@@ -0,0 +1,173 @@
+// Package gitignore implements matching of paths against the patterns of
+// .gitignore files.  It supports the subset of gitignore(5) that is relevant
+// for vgrep's built-in search: comments, negations, directory-only patterns,
+// anchored patterns and the "*", "?", "[...]" and "**" wildcards.
+//
+// (c) 2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+package gitignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pattern is a single compiled line of a .gitignore file.
+type pattern struct {
+	regex   *regexp.Regexp // compiled glob
+	negate  bool           // pattern starts with "!"
+	dirOnly bool           // pattern ends with "/"
+}
+
+// Matcher holds the patterns of one .gitignore file.  Patterns are matched
+// relative to the directory of the file.
+type Matcher struct {
+	base     string // absolute directory of the .gitignore file
+	patterns []pattern
+}
+
+// Stack is a list of Matchers ordered from the outermost to the innermost
+// directory.  Patterns of inner directories take precedence.
+type Stack []*Matcher
+
+// New parses the patterns read from r.  base is the absolute path of the
+// directory the patterns are relative to.
+func New(base string, r io.Reader) (*Matcher, error) {
+	m := &Matcher{base: filepath.Clean(base)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := compile(scanner.Text()); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, scanner.Err()
+}
+
+// ReadDir loads the .gitignore file in the absolute directory dir.  It returns
+// nil if the directory does not contain a .gitignore file.
+func ReadDir(dir string) (*Matcher, error) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return New(dir, file)
+}
+
+// Match returns whether the absolute path is matched by any pattern of m and,
+// if so, whether it is ignored or explicitly included via a negation.
+func (m *Matcher) Match(path string, isDir bool) (matched, ignored bool) {
+	rel, err := filepath.Rel(m.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// The last matching pattern decides.
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		p := m.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.regex.MatchString(rel) {
+			return true, !p.negate
+		}
+	}
+	return false, false
+}
+
+// Ignored returns true if the absolute path is ignored by the Matchers in s.
+func (s Stack) Ignored(path string, isDir bool) bool {
+	for i := len(s) - 1; i >= 0; i-- {
+		if matched, ignored := s[i].Match(path, isDir); matched {
+			return ignored
+		}
+	}
+	return false
+}
+
+// compile converts a line of a .gitignore file into a pattern.  The returned
+// bool is false for blank lines and comments.
+func compile(line string) (pattern, bool) {
+	var p pattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+
+	// Patterns without a slash match at any depth, all others are
+	// relative to the directory of the .gitignore file.
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !strings.Contains(line, "/") {
+		expr.WriteString("(?:.*/)?")
+	}
+	line = strings.TrimPrefix(line, "/")
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(line[i:], "**/") {
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(line[i:], "**") {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(line[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	regex, err := regexp.Compile(expr.String())
+	if err != nil {
+		return p, false
+	}
+	p.regex = regex
+	return p, true
+}
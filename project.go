@@ -0,0 +1,45 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// projectRoot returns the root of the project workDir belongs to, which is
+// the toplevel directory of the git tree or, outside of git, workDir.
+func projectRoot(workDir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		logrus.Debugf("not inside a git tree: %v", err)
+		return workDir
+	}
+	root, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		logrus.Debugf("error resolving git toplevel: %v", err)
+		return workDir
+	}
+	return root
+}
+
+// projectKey returns the name of the directory of the project's cache.  The
+// hash of the path keeps projects with the same name apart while the name
+// keeps the directory recognizable.
+func projectKey(root string) string {
+	name := filepath.Base(root)
+	if name == string(filepath.Separator) || name == "." {
+		name = "root"
+	}
+	sum := sha256.Sum256([]byte(root))
+	return name + "-" + hex.EncodeToString(sum[:])[:12]
+}
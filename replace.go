@@ -0,0 +1,201 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// diffContext is the number of context lines in the diff preview of replace.
+const diffContext = 3
+
+// isReplaceCommand returns true if input is a replace command, for instance,
+// "x/old/new/ 1-5" or "replace /old/new/ 1-5".
+func isReplaceCommand(input string) bool {
+	if strings.HasPrefix(input, "replace ") {
+		return true
+	}
+	if len(input) < 2 || input[0] != 'x' {
+		return false
+	}
+	return isReplaceDelimiter(input[1])
+}
+
+// isReplaceDelimiter returns true if c can delimit the fields of a replace
+// expression.  Letters, digits, spaces and backslashes cannot.
+func isReplaceDelimiter(c byte) bool {
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ' ' || c == '\\')
+}
+
+// parseReplaceExpr parses the sed-like expression of the replace command
+// (e.g., "/old/new/ 1-5") and returns the pattern, the replacement and the
+// selectors.  The first character is used as the delimiter, which can be
+// escaped with a backslash in the pattern and the replacement.
+func parseReplaceExpr(expr string) (string, string, string, error) {
+	expr = strings.TrimLeft(expr, " ")
+	if len(expr) == 0 {
+		return "", "", "", fmt.Errorf("replace expects an expression of the form %q", "/regexp/replacement/ [selectors]")
+	}
+	delim := expr[0]
+	if !isReplaceDelimiter(delim) {
+		return "", "", "", fmt.Errorf("invalid delimiter %q of replace expression", delim)
+	}
+
+	var fields []string
+	var field strings.Builder
+	i := 1
+	for ; i < len(expr) && len(fields) < 2; i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == delim:
+			field.WriteByte(delim)
+			i++
+		case expr[i] == delim:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(expr[i])
+		}
+	}
+	if len(fields) != 2 {
+		return "", "", "", fmt.Errorf("unterminated replace expression %q", expr)
+	}
+	if len(fields[0]) == 0 {
+		return "", "", "", fmt.Errorf("replace expects a non-empty regexp")
+	}
+	return fields[0], fields[1], expr[i:], nil
+}
+
+// commandReplace replaces all occurrences of a regexp with a replacement in
+// the matched lines specified by the selectors in expr (e.g., "/old/new/ 1-5").
+// A diff of the changes is printed before asking for confirmation.  Lines that
+// changed since the search are not touched.
+func (v *vgrep) commandReplace(expr string) bool {
+	pattern, replacement, selectors, err := parseReplaceExpr(expr)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Printf("failed to compile '%s' as a regexp\n", pattern)
+		return false
+	}
+	indices, err := v.parseSelectors(selectors)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if len(indices) == 0 {
+		fmt.Println("replace requires specified selectors")
+		return false
+	}
+	if indices, err = v.checkIndices(indices); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	logrus.Debugf("commandReplace(pattern=%q, replacement=%q, indices=%v)", pattern, replacement, indices)
+
+	files, changed := v.prepareEdits(indices, func(edit *lineEdit) bool {
+		edit.new, edit.spans = substitute(regex, edit.old, replacement)
+		return edit.new != edit.old
+	})
+	if changed == 0 {
+		fmt.Println("no lines to change")
+		return false
+	}
+	for _, file := range files {
+		fmt.Print(unifiedDiff(file))
+	}
+	if !v.confirm(fmt.Sprintf("Replace %d line(s)? [y/N] ", changed)) {
+		fmt.Println("no lines changed")
+		return false
+	}
+
+	changed = v.writeEdits(files)
+	fmt.Printf("replaced %d line(s)\n", changed)
+
+	return false
+}
+
+// substitute replaces all matches of regex in str with replacement, which may
+// refer to submatches (e.g., "$1").  It returns the new string along with the
+// byte offsets of the replaced text.
+func substitute(regex *regexp.Regexp, str string, replacement string) (string, [][]int) {
+	var out []byte
+	var spans [][]int
+	last := 0
+	for _, submatches := range regex.FindAllStringSubmatchIndex(str, -1) {
+		out = append(out, str[last:submatches[0]]...)
+		start := len(out)
+		out = regex.ExpandString(out, replacement, str, submatches)
+		spans = append(spans, []int{start, len(out)})
+		last = submatches[1]
+	}
+	out = append(out, str[last:]...)
+	return string(out), spans
+}
+
+// unifiedDiff returns the diff of the edits in file in the unified format.
+func unifiedDiff(file *fileEdits) string {
+	name, lines, edits := file.name, file.lines, file.edits
+	numLines := len(lines)
+	if numLines > 0 && lines[numLines-1] == "" {
+		numLines--
+	}
+
+	var diff strings.Builder
+	diff.WriteString(ansi.Bold("--- a/"+name) + "\n")
+	diff.WriteString(ansi.Bold("+++ b/"+name) + "\n")
+
+	for i := 0; i < len(edits); {
+		// Merge edits with overlapping context into one hunk.
+		j := i + 1
+		for j < len(edits) && edits[j].line-edits[j-1].line <= 2*diffContext {
+			j++
+		}
+		start := max(1, edits[i].line-diffContext)
+		end := min(numLines, edits[j-1].line+diffContext)
+		count := end - start + 1
+		diff.WriteString(ansi.Color(fmt.Sprintf("@@ -%d,%d +%d,%d @@", start, count, start, count), ansi.CYAN, false) + "\n")
+
+		k := i
+		for l := start; l <= end; l++ {
+			if k < j && edits[k].line == l {
+				diff.WriteString(ansi.Color("-"+edits[k].old, ansi.RED, false) + "\n")
+				diff.WriteString(ansi.Color("+"+edits[k].new, ansi.GREEN, false) + "\n")
+				k++
+				continue
+			}
+			diff.WriteString(" " + lineContent(lines[l-1]) + "\n")
+		}
+		i = j
+	}
+
+	return diff.String()
+}
+
+// confirm asks the user the specified question and returns true if the
+// answer is yes.
+func (v *vgrep) confirm(question string) bool {
+	var answer string
+	var err error
+	if v.prompt != nil {
+		answer, err = v.prompt.Prompt(question)
+	} else {
+		fmt.Print(question)
+		_, err = fmt.Scanln(&answer)
+	}
+	if err != nil {
+		logrus.Debugf("error reading answer: %v", err)
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,169 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"golang.org/x/term"
+)
+
+// rerun runs the search of the current matches again in its working directory
+// and prints the new results compared to the previous ones.  It returns true
+// if the search has been cancelled.
+func (v *vgrep) rerun() (bool, error) {
+	if v.searchBackend == "" {
+		return false, errors.New("cannot rerun results of a previous version of vgrep")
+	}
+	logrus.Debugf("rerun(): %s in %s", searchCommand(v.searchBackend, v.query), v.workDir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, fmt.Errorf("error getting working dir: %v", err)
+	}
+	if err := os.Chdir(v.workDir); err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := os.Chdir(cwd); err != nil {
+			logrus.Warnf("error changing back to %s: %v", cwd, err)
+		}
+	}()
+
+	// Search with the same backend, which determines the syntax of the
+	// arguments.
+	backend := v.Backend
+	v.Backend = v.searchBackend
+	defer func() { v.Backend = backend }()
+
+	previous := v.matches
+	cancelled := v.runSearch(v.query, false)
+	if cancelled && !v.KeepPartial {
+		return true, nil
+	}
+	v.printDiff(previous)
+	return cancelled, nil
+}
+
+// commandRerun runs the search of the current matches again and prints the
+// changes of the results.
+func (v *vgrep) commandRerun() bool {
+	if _, err := v.rerun(); err != nil {
+		fmt.Println(err)
+	}
+	v.waiter.Wait()
+	return false
+}
+
+// diffMatches compares the matches with the previous ones.  Matches are
+// considered the same if their files and contents are, as line numbers change
+// when editing the files.  diffMatches returns whether each match is new
+// ('+') or unchanged ('='), along with the previous matches that are gone.
+func diffMatches(previous, matches [][]string) ([]byte, [][]string) {
+	key := func(m []string) string {
+		return m[1] + "\x00" + ansi.RemoveANSI(m[3])
+	}
+
+	unmatched := make(map[string][]int)
+	for i, m := range previous {
+		k := key(m)
+		unmatched[k] = append(unmatched[k], i)
+	}
+
+	markers := make([]byte, len(matches))
+	found := make([]bool, len(previous))
+	for i, m := range matches {
+		k := key(m)
+		if candidates := unmatched[k]; len(candidates) > 0 {
+			found[candidates[0]] = true
+			unmatched[k] = candidates[1:]
+			markers[i] = '='
+			continue
+		}
+		markers[i] = '+'
+	}
+
+	var gone [][]string
+	for i, m := range previous {
+		if !found[i] {
+			gone = append(gone, m)
+		}
+	}
+	return markers, gone
+}
+
+// printDiff prints the current matches compared to the previous ones.  New
+// matches are marked with '+' and unchanged ones with '=' in the index
+// column, while matches that are gone are listed with '-' among the matches
+// of their files.
+func (v *vgrep) printDiff(previous [][]string) {
+	markers, gone := diffMatches(previous, v.matches)
+
+	type diffRow struct {
+		file int // position of the file
+		line int
+		row  []string
+	}
+	var rows []diffRow
+	files := make(map[string]int)
+	add := func(m []string, index string) {
+		position, exists := files[m[1]]
+		if !exists {
+			position = len(files)
+			files[m[1]] = position
+		}
+		line, _ := strconv.Atoi(m[2])
+		row := v.formatMatch(m)
+		row = append([]string{index}, row[1:]...)
+		rows = append(rows, diffRow{file: position, line: line, row: row})
+	}
+
+	added, unchanged := 0, 0
+	for i, m := range v.matches {
+		if markers[i] == '+' {
+			added++
+		} else {
+			unchanged++
+		}
+		add(m, string(markers[i])+m[0])
+	}
+	for _, m := range gone {
+		add(m, "-")
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].file != rows[j].file {
+			return rows[i].file < rows[j].file
+		}
+		return rows[i].line < rows[j].line
+	})
+
+	if !v.NoHeader {
+		fmt.Printf("rerun of %s in %s: %d new, %d gone, %d unchanged\n",
+			searchLabel(v.searchBackend, v.query), v.workDir, added, len(gone), unchanged)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, v.matchHeader())
+	}
+	for _, r := range rows {
+		toPrint = append(toPrint, r.row)
+	}
+
+	useLess := !v.NoLess && term.IsTerminal(int(os.Stdout.Fd()))
+	cw := v.matchWriter(useLess)
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+}
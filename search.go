@@ -0,0 +1,255 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// searcher is a search backend such as grep or ripgrep.  Backends are
+// registered in searchers and selected either automatically or via the
+// --backend flag.
+type searcher interface {
+	// Name returns the name of the backend as used by --backend.
+	Name() string
+	// Detect returns true if the backend can be used for searching.
+	Detect(v *vgrep) bool
+	// Command returns the command line to search for args.
+	Command(args []string) []string
+	// Env returns the environment the command runs in.
+	Env() []string
+	// Parse parses a line of the command's output.  It returns nil if the
+	// line does not describe a matched line.
+	Parse(output string) (*searchResult, error)
+}
+
+// searchResult is a matched line as reported by a backend.
+type searchResult struct {
+	file    string
+	line    string
+	content string  // highlighted via ANSI codes by the backend unless spans are set
+	spans   [][]int // byte offsets of the matched text in content
+}
+
+// directSearcher is implemented by backends that search without running an
+// external command.
+type directSearcher interface {
+	// Search searches for args and stores the results in v.matches.
+	Search(v *vgrep, args []string)
+}
+
+// matchSplitter is implemented by backends that report each match in a line
+// separately, such that the results of a line must be merged.
+type matchSplitter interface {
+	// SplitsMatches returns true if matches are reported separately.
+	SplitsMatches() bool
+}
+
+// searchers lists all backends in the order of auto-detection.
+var searchers = []searcher{
+	&ripgrepSearcher{},
+	&ugrepSearcher{},
+	&agSearcher{},
+	&gitGrepSearcher{},
+	&grepSearcher{},
+	&ackSearcher{},
+	&nativeSearcher{},
+}
+
+// backendNames returns the names of all registered backends.
+func backendNames() []string {
+	names := make([]string, len(searchers))
+	for i, s := range searchers {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// searcher returns the backend to search with.  It is either the one
+// specified via --backend or the first detected one.
+func (v *vgrep) searcher() (searcher, error) {
+	name := v.Backend
+	if v.Native && name == "" {
+		name = "native"
+	}
+
+	if name != "" {
+		for _, s := range searchers {
+			if s.Name() != name {
+				continue
+			}
+			if !s.Detect(v) {
+				return nil, fmt.Errorf("backend %q is not available", name)
+			}
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown backend %q (choose from %s)", name, strings.Join(backendNames(), ", "))
+	}
+
+	for _, s := range searchers {
+		if s.Detect(v) {
+			logrus.Debugf("detected backend %q", s.Name())
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no search backend found")
+}
+
+// installed returns true if the program is found in $PATH.
+func installed(program string) bool {
+	_, err := exec.LookPath(program)
+	logrus.Debugf("installed(%s) -> %v", program, err == nil)
+	return err == nil
+}
+
+// grep greps with the specified args and stores the results in v.matches.
+func (v *vgrep) grep(args []string) {
+	backend, err := v.searcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
+		os.Exit(1)
+	}
+	v.query = args
+	v.searchBackend = backend.Name()
+	v.searchTime = time.Now()
+
+	if direct, ok := backend.(directSearcher); ok {
+		direct.Search(v, args)
+		return
+	}
+
+	splitter, splits := backend.(matchSplitter)
+	splits = splits && splitter.SplitsMatches()
+
+	v.matches = [][]string{}
+	var last *searchResult
+	err = v.streamCommand(backend.Command(args), backend.Env(), func(m string) {
+		res, err := backend.Parse(m)
+		if err != nil {
+			logrus.Debugf("skipping line %q (parse error: %v)", m, err)
+			return
+		}
+		if res == nil {
+			return
+		}
+		if !splits {
+			v.matches = append(v.matches, res.row(len(v.matches)))
+			v.printMatch(len(v.matches) - 1)
+			return
+		}
+		// Merge matches in the same line into one row, which can
+		// only be printed once the next line has been reported.
+		if last != nil && last.file == res.file && last.line == res.line {
+			last.spans = append(last.spans, res.spans...)
+			v.matches[len(v.matches)-1] = last.row(len(v.matches) - 1)
+			return
+		}
+		v.printMatch(len(v.matches) - 1)
+		v.matches = append(v.matches, res.row(len(v.matches)))
+		last = res
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
+		os.Exit(1)
+	}
+	if splits {
+		v.printMatch(len(v.matches) - 1)
+	}
+
+	logrus.Debugf("found %d matches", len(v.matches))
+}
+
+// printMatch prints the match at the specified index while searching if
+// matches are printed as they are found.
+func (v *vgrep) printMatch(index int) {
+	if v.printer != nil && index >= 0 {
+		v.printer.add(index)
+	}
+}
+
+// row returns res as a row of v.matches at the specified index.  If the
+// backend did not report spans, they are computed from the backend's
+// highlighting, so that all matches are highlighted by vgrep.
+func (res *searchResult) row(index int) []string {
+	content, spans := res.content, res.spans
+	if spans == nil {
+		content, spans = ansi.Spans(content)
+	}
+	column := ""
+	if len(spans) > 0 {
+		column = strconv.Itoa(spans[0][0] + 1)
+	}
+	return []string{strconv.Itoa(index), res.file, res.line, highlight(content, spans), column, formatSpans(spans)}
+}
+
+// highlight colors the spans in content.
+func highlight(content string, spans [][]int) string {
+	var out strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span[0] == span[1] || span[0] < last || span[1] > len(content) {
+			continue
+		}
+		out.WriteString(content[last:span[0]])
+		out.WriteString(ansi.Color(content[span[0]:span[1]], ansi.RED, true))
+		last = span[1]
+	}
+	out.WriteString(content[last:])
+	return out.String()
+}
+
+// formatSpans encodes spans as "start:end,start:end" to store them in the
+// cache.
+func formatSpans(spans [][]int) string {
+	encoded := make([]string, len(spans))
+	for i, span := range spans {
+		encoded[i] = fmt.Sprintf("%d:%d", span[0], span[1])
+	}
+	return strings.Join(encoded, ",")
+}
+
+// splitFields splits match at the first two occurrences of separator into its
+// file, line and content.
+func splitFields(match string, separator []byte) (*searchResult, error) {
+	spl := bytes.SplitN([]byte(match), separator, 3)
+	if len(spl) < 3 {
+		return nil, fmt.Errorf("expected %d but split into %d items (%v)", 3, len(spl), separator)
+	}
+	return &searchResult{file: string(spl[0]), line: string(spl[1]), content: string(spl[2])}, nil
+}
+
+// splitNul splits match into its file, line and content where the file is
+// terminated by a NUL byte and the line by a colon.  ANSI codes are removed
+// from the file and line in case the backend colors them.
+func splitNul(match string) (*searchResult, error) {
+	separator := []byte{0}
+	spl := bytes.SplitN([]byte(match), separator, 2)
+	if len(spl) < 2 {
+		return nil, fmt.Errorf("expected %d but split into %d items (%v)", 2, len(spl), separator)
+	}
+	splline := bytes.SplitN(spl[1], []byte(":"), 2)
+	if len(splline) != 2 {
+		// Fall back to "-" which is used when displaying
+		// context lines.
+		splline = bytes.SplitN(spl[1], []byte("-"), 2)
+	}
+	if len(splline) == 2 {
+		return &searchResult{
+			file:    ansi.RemoveANSI(string(spl[0])),
+			line:    ansi.RemoveANSI(string(splline[0])),
+			content: string(splline[1]),
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected input")
+}
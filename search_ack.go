@@ -0,0 +1,78 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ackSeparator separates the fields of ackOutput.  ack cannot print NUL
+// bytes via --output, so the ASCII unit separator is used instead.  Lines
+// containing the separator elsewhere are ambiguous and skipped.
+const ackSeparator = "\x1f"
+
+// ackOutput is the expression passed to ack's --output flag.  ack only fills
+// in a fixed set of variables, so the offsets of the match are computed from
+// the lengths of the text before ($`) and of the match ($&).
+var ackOutput = strings.Join([]string{"$f", "$.", "$`", "$&", "$'"}, ackSeparator)
+
+// ackSearcher searches with ack.
+type ackSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *ackSearcher) Name() string {
+	return "ack"
+}
+
+// Detect returns true if ack is installed.
+func (s *ackSearcher) Detect(v *vgrep) bool {
+	return installed("ack")
+}
+
+// Command returns the ack command line to search for args.  ack does not
+// support NUL-terminated file names in its regular output, so vgrep defines
+// the output format via --output.  --noenv ignores the user's .ackrc files
+// and ACK_OPTIONS, which may change the output.
+func (s *ackSearcher) Command(args []string) []string {
+	cmd := []string{
+		"ack", "--noenv", "--nocolor", "--noheading", "--nobreak", "--no-filename",
+		"--output=" + ackOutput,
+	}
+	return append(cmd, args...)
+}
+
+// Env returns nil to run ack in an empty environment.
+func (s *ackSearcher) Env() []string {
+	return nil
+}
+
+// SplitsMatches returns true since ack reports each match separately.
+func (s *ackSearcher) SplitsMatches() bool {
+	return true
+}
+
+// Parse splits a line of ack's output.  Each match is reported in a separate
+// line, which are merged by vgrep.  Lines with more separators than fields
+// are rejected since the file name or the line contains the separator, so
+// the fields cannot be told apart.
+func (s *ackSearcher) Parse(output string) (*searchResult, error) {
+	spl := strings.Split(output, ackSeparator)
+	if len(spl) != 5 {
+		return nil, fmt.Errorf("expected %d but split into %d items (%q)", 5, len(spl), ackSeparator)
+	}
+	if _, err := strconv.Atoi(spl[1]); err != nil {
+		return nil, fmt.Errorf("invalid line number %q: %w", spl[1], err)
+	}
+	start := len(spl[2])
+	end := start + len(spl[3])
+	return &searchResult{
+		file:    spl[0],
+		line:    spl[1],
+		content: spl[2] + spl[3] + spl[4],
+		spans:   [][]int{{start, end}},
+	}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+// agSearcher searches with the silver searcher.
+type agSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *agSearcher) Name() string {
+	return "ag"
+}
+
+// Detect returns true if the silver searcher is installed.
+func (s *agSearcher) Detect(v *vgrep) bool {
+	return installed("ag")
+}
+
+// Command returns the ag command line to search for args.  File names are
+// terminated by a NUL byte and only the matched text is colored.
+func (s *agSearcher) Command(args []string) []string {
+	cmd := []string{
+		"ag", "--nogroup", "--numbers", "--filename", "--null", "--color",
+		"--color-match=1;31", "--color-path=0", "--color-line-number=0",
+	}
+	return append(cmd, args...)
+}
+
+// Env returns nil to run ag in an empty environment.
+func (s *agSearcher) Env() []string {
+	return nil
+}
+
+// Parse splits a line of ag's output.
+func (s *agSearcher) Parse(output string) (*searchResult, error) {
+	return splitNul(output)
+}
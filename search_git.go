@@ -0,0 +1,43 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+// gitGrepSearcher searches with git-grep.
+type gitGrepSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *gitGrepSearcher) Name() string {
+	return "git"
+}
+
+// Detect returns true if the working directory is inside a git tree and
+// git-grep is not disabled via --no-git.
+func (s *gitGrepSearcher) Detect(v *vgrep) bool {
+	if v.NoGit && v.Backend == "" {
+		return false
+	}
+	return v.insideGitTree()
+}
+
+// Command returns the git-grep command line to search for args.  Only the
+// matched text is colored, which allows for computing the column of matches.
+func (s *gitGrepSearcher) Command(args []string) []string {
+	cmd := []string{"git"}
+	for _, slot := range []string{"context", "filename", "lineNumber", "selected", "separator"} {
+		cmd = append(cmd, "-c", "color.grep."+slot+"=normal")
+	}
+	cmd = append(cmd, "-c", "color.grep.match=red bold", "grep", "-z", "-In", "--color=always")
+	return append(cmd, args...)
+}
+
+// Env makes sure that git-grep ignores the user's git config.
+func (s *gitGrepSearcher) Env() []string {
+	return []string{"HOME="}
+}
+
+// Parse splits a line of git-grep's output.
+func (s *gitGrepSearcher) Parse(output string) (*searchResult, error) {
+	return splitFields(output, []byte{0})
+}
@@ -0,0 +1,81 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"regexp"
+	"runtime"
+)
+
+// the variant of the underlying grep program
+const (
+	BSDGrep = "BSD"
+	GNUGrep = "GNU"
+)
+
+// grepSearcher searches with GNU or BSD grep.
+type grepSearcher struct {
+	variant string // BSDGrep or GNUGrep
+	openBSD bool   // grep of OpenBSD does not support colors
+}
+
+// Name returns the name of the backend.
+func (s *grepSearcher) Name() string {
+	return "grep"
+}
+
+// Detect returns true if a known variant of grep is installed.
+func (s *grepSearcher) Detect(v *vgrep) bool {
+	s.variant = s.getGrepType(v)
+	if s.variant == "" && runtime.GOOS == "openbsd" {
+		// grep --version = "grep version 0.9"
+		s.variant = BSDGrep
+		s.openBSD = true
+	}
+	return s.variant != ""
+}
+
+// getGrepType returns the variant of the installed grep.
+func (s *grepSearcher) getGrepType(v *vgrep) string {
+	out, _ := v.runCommand([]string{"grep", "--version"}, nil)
+	if len(out) == 0 {
+		return ""
+	}
+	versionString := out[0]
+	// versionString = "grep (BSD grep) 2.5.1-FreeBSD"
+	// versionString = "grep (BSD grep, GNU compatible) 2.6.0-FreeBSD"
+	versionRegex := regexp.MustCompile(`\(([[:alpha:]]+) grep`)
+	// versionRegex matches to ["(BSD grep)", "BSD"], return "BSD"
+	submatch := versionRegex.FindStringSubmatch(versionString)
+	if len(submatch) < 2 {
+		return ""
+	}
+	return submatch[1]
+}
+
+// Command returns the grep command line to search for args.
+func (s *grepSearcher) Command(args []string) []string {
+	cmd := []string{"grep", "-ZHInr"}
+	if !s.openBSD {
+		cmd = append(cmd, "--color=always")
+	}
+	return append(cmd, args...)
+}
+
+// Env configures grep to only color matches.
+func (s *grepSearcher) Env() []string {
+	if s.openBSD {
+		return nil
+	}
+	return []string{"GREP_COLORS='ms=01;31:mc=:sl=:cx=:fn=:ln=:se=:bn='"}
+}
+
+// Parse splits a line of grep's output.
+func (s *grepSearcher) Parse(output string) (*searchResult, error) {
+	if s.variant == BSDGrep {
+		return splitFields(output, []byte(":"))
+	}
+	return splitNul(output)
+}
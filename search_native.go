@@ -0,0 +1,424 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/gitignore"
+)
+
+// binaryProbeSize is the number of bytes inspected for NUL bytes to decide if
+// a file is binary.  Same heuristic as git.
+const binaryProbeSize = 8000
+
+// nativeOptions are the grep flags understood by the built-in search.
+type nativeOptions struct {
+	patterns   []string
+	paths      []string
+	ignoreCase bool
+	wordRegexp bool
+	fixed      bool
+	text       bool
+}
+
+// nativeResult holds the matches of a single file.
+type nativeResult struct {
+	matches []*searchResult
+	err     error
+}
+
+// nativeSearcher searches with the built-in search.  It serves as a fallback
+// if no other backend is available.
+type nativeSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *nativeSearcher) Name() string {
+	return "native"
+}
+
+// Detect returns true since the built-in search is always available.
+func (s *nativeSearcher) Detect(v *vgrep) bool {
+	return true
+}
+
+// Command returns nil since the built-in search does not run a command.
+func (s *nativeSearcher) Command(args []string) []string {
+	return nil
+}
+
+// Env returns nil since the built-in search does not run a command.
+func (s *nativeSearcher) Env() []string {
+	return nil
+}
+
+// Parse is not supported since the built-in search does not run a command.
+func (s *nativeSearcher) Parse(output string) (*searchResult, error) {
+	return nil, errors.New("not supported by the built-in search")
+}
+
+// Search searches for args and stores the results in v.matches.
+func (s *nativeSearcher) Search(v *vgrep, args []string) {
+	v.nativeGrep(args)
+}
+
+// nativeIgnoredFlags are long flags of grep that do not change which lines
+// match and are ignored by the built-in search.  Patterns are Go regular
+// expressions, which are close to extended ones, so --extended-regexp is
+// ignored while --basic-regexp is rejected.
+var nativeIgnoredFlags = map[string]bool{
+	"--after-context": true, "--before-context": true,
+	"--color": true, "--colour": true, "--context": true,
+	"--dereference-recursive": true, "--extended-regexp": true,
+	"--line-number": true, "--no-messages": true, "--recursive": true,
+	"--with-filename": true,
+}
+
+// parseNativeArgs parses the grep-like args for the built-in search.  Flags
+// that do not change which lines match, such as the ones for context lines,
+// are ignored with a warning.  Other unsupported flags are an error, since
+// ignoring them would yield wrong results (e.g., -v, -x or -G).  Note that -w
+// surrounds the patterns with \b, which differs from grep for patterns that
+// start or end with a non-word character.
+func parseNativeArgs(args []string) (*nativeOptions, error) {
+	opts := &nativeOptions{}
+	var positional []string
+
+	// Ignored flags of grep that consume the next argument.
+	withValue := map[string]bool{
+		"A": true, "B": true, "C": true,
+		"--after-context": true, "--before-context": true, "--context": true,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case arg == "-e" || arg == "--regexp":
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("flag %q requires an argument", arg)
+			}
+			i++
+			opts.patterns = append(opts.patterns, args[i])
+		case strings.HasPrefix(arg, "--regexp="):
+			opts.patterns = append(opts.patterns, strings.TrimPrefix(arg, "--regexp="))
+		case arg == "--ignore-case":
+			opts.ignoreCase = true
+		case arg == "--word-regexp":
+			opts.wordRegexp = true
+		case arg == "--fixed-strings":
+			opts.fixed = true
+		case arg == "--text":
+			opts.text = true
+		case strings.HasPrefix(arg, "--"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if !nativeIgnoredFlags[name] {
+				return nil, fmt.Errorf("unsupported flag %q", arg)
+			}
+			logrus.Warnf("native search: ignoring unsupported flag %q", arg)
+			if withValue[name] && !hasValue && i+1 < len(args) {
+				i++
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				flag := string(arg[j])
+				switch flag {
+				case "i":
+					opts.ignoreCase = true
+				case "w":
+					opts.wordRegexp = true
+				case "F":
+					opts.fixed = true
+				case "a":
+					opts.text = true
+				case "E", "H", "I", "n", "r", "R", "s":
+					// Implied by the built-in search.
+				case "e":
+					if j+1 < len(arg) {
+						opts.patterns = append(opts.patterns, arg[j+1:])
+					} else if i+1 < len(args) {
+						i++
+						opts.patterns = append(opts.patterns, args[i])
+					} else {
+						return nil, fmt.Errorf("flag %q requires an argument", "-e")
+					}
+					j = len(arg)
+				case "A", "B", "C":
+					logrus.Warnf("native search: ignoring unsupported flag %q", "-"+flag)
+					if withValue[flag] {
+						if j+1 == len(arg) && i+1 < len(args) {
+							i++
+						}
+						j = len(arg)
+					}
+				default:
+					return nil, fmt.Errorf("unsupported flag %q", "-"+flag)
+				}
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if len(opts.patterns) == 0 {
+		if len(positional) == 0 {
+			return nil, errors.New("no pattern specified")
+		}
+		opts.patterns = positional[:1]
+		positional = positional[1:]
+	}
+	opts.paths = positional
+	if len(opts.paths) == 0 {
+		opts.paths = []string{"."}
+	}
+	return opts, nil
+}
+
+// regexp compiles the patterns in opts into a single regular expression.
+func (opts *nativeOptions) regexp() (*regexp.Regexp, error) {
+	patterns := make([]string, len(opts.patterns))
+	for i, p := range opts.patterns {
+		if opts.fixed {
+			p = regexp.QuoteMeta(p)
+		}
+		patterns[i] = "(?:" + p + ")"
+	}
+	expr := strings.Join(patterns, "|")
+	if opts.wordRegexp {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if opts.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// nativeGrep searches args with the built-in search and stores the results in
+// v.matches.
+func (v *vgrep) nativeGrep(args []string) {
+	logrus.Debugf("nativeGrep(args=%s)", args)
+
+	opts, err := parseNativeArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
+		os.Exit(1)
+	}
+	regex, err := opts.regexp()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Read the channel once, since stopSearch resets it after the search
+	// returns while the workers may still be running.
+	cancel := v.cancelled()
+
+	files, err := nativeWalk(opts.paths, cancel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Search the files in parallel but keep the order of the walk to
+	// return deterministic results.  The results of a file are added as
+	// soon as the ones of all previous files have been added.
+	results := make([]nativeResult, len(files))
+	done := make([]chan struct{}, len(files))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	jobs := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			for i := range jobs {
+				select {
+				case <-cancel:
+					return
+				default:
+				}
+				results[i] = nativeSearchFile(files[i], regex, opts.text)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-cancel:
+				return
+			}
+		}
+	}()
+
+	v.matches = [][]string{}
+collect:
+	for i := range results {
+		select {
+		case <-done[i]:
+		case <-cancel:
+			break collect
+		}
+		res := results[i]
+		if res.err != nil {
+			logrus.Errorf("%v", res.err)
+			v.exitCode = 2
+			continue
+		}
+		for _, m := range res.matches {
+			v.matches = append(v.matches, m.row(len(v.matches)))
+			v.printMatch(len(v.matches) - 1)
+		}
+	}
+
+	logrus.Debugf("found %d matches", len(v.matches))
+}
+
+// nativeWalk returns all regular files below paths that are not ignored by a
+// .gitignore file.  Paths passed explicitly are never ignored.  The walk stops
+// once cancel is closed.
+func nativeWalk(paths []string, cancel <-chan struct{}) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	abs := func(p string) string {
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(cwd, p)
+	}
+
+	var files []string
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+
+		// Maps directories to the .gitignore files in effect.
+		stacks := make(map[string]gitignore.Stack)
+		stacks[root] = parentIgnores(abs(root))
+
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			select {
+			case <-cancel:
+				return filepath.SkipAll
+			default:
+			}
+			if err != nil {
+				logrus.Errorf("%v", err)
+				return nil
+			}
+			if p == root {
+				if m, err := gitignore.ReadDir(abs(p)); err == nil && m != nil {
+					stacks[p] = append(stacks[p], m)
+				}
+				return nil
+			}
+
+			stack := stacks[filepath.Dir(p)]
+			if d.IsDir() {
+				if d.Name() == ".git" || stack.Ignored(abs(p), true) {
+					return filepath.SkipDir
+				}
+				stacks[p] = stack
+				if m, err := gitignore.ReadDir(abs(p)); err != nil {
+					logrus.Errorf("%v", err)
+				} else if m != nil {
+					stacks[p] = append(stack[:len(stack):len(stack)], m)
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || stack.Ignored(abs(p), false) {
+				return nil
+			}
+			files = append(files, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parentIgnores returns the .gitignore files of all parent directories of dir
+// up to the root of the enclosing git tree.  Outside of a git tree, parent
+// .gitignore files do not apply.
+func parentIgnores(dir string) gitignore.Stack {
+	var stack gitignore.Stack
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return stack
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		if m, err := gitignore.ReadDir(parent); err == nil && m != nil {
+			stack = append(gitignore.Stack{m}, stack...)
+		}
+		dir = parent
+	}
+}
+
+// nativeSearchFile searches path for regex and returns the matches.  Binary
+// files are skipped unless text is set.
+func nativeSearchFile(path string, regex *regexp.Regexp, text bool) nativeResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = fmt.Errorf("%s: %v", path, pathErr.Err)
+		}
+		return nativeResult{err: err}
+	}
+
+	if !text && bytes.IndexByte(data[:min(len(data), binaryProbeSize)], 0) >= 0 {
+		return nativeResult{}
+	}
+
+	var res nativeResult
+	lineNum := 0
+	for len(data) > 0 {
+		lineNum++
+		line := data
+		if end := bytes.IndexByte(data, '\n'); end >= 0 {
+			line, data = data[:end], data[end+1:]
+		} else {
+			data = nil
+		}
+		spans := regex.FindAllIndex(line, -1)
+		if len(spans) == 0 {
+			continue
+		}
+		res.matches = append(res.matches, &searchResult{
+			file:    path,
+			line:    strconv.Itoa(lineNum),
+			content: string(line),
+			spans:   spans,
+		})
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ripgrepSearcher searches with ripgrep.
+type ripgrepSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *ripgrepSearcher) Name() string {
+	return "rg"
+}
+
+// Detect returns true if ripgrep is installed and not disabled via
+// --no-ripgrep.
+func (s *ripgrepSearcher) Detect(v *vgrep) bool {
+	if v.NoRipgrep && v.Backend == "" {
+		return false
+	}
+
+	return installed("rg")
+}
+
+// Command returns the ripgrep command line to search for args.  ripgrep
+// reports matches as a stream of JSON events, which carry the exact byte
+// offsets of the matched text independent of the user's color settings.
+func (s *ripgrepSearcher) Command(args []string) []string {
+	cmd := []string{"rg", "--json"}
+	return append(cmd, args...)
+}
+
+// Env passes on the user's ripgrep configuration.
+func (s *ripgrepSearcher) Env() []string {
+	if config := os.Getenv("RIPGREP_CONFIG_PATH"); len(config) != 0 {
+		return []string{"RIPGREP_CONFIG_PATH=" + config}
+	}
+	return nil
+}
+
+// ripgrepData is the text or, if it is not valid UTF-8, the base64 encoded
+// bytes of a path or line in ripgrep's JSON output.
+type ripgrepData struct {
+	Text  *string `json:"text"`
+	Bytes *string `json:"bytes"`
+}
+
+// ripgrepEvent is a single message in ripgrep's JSON output.  Only the fields
+// of "match" and "context" messages are relevant to vgrep.
+type ripgrepEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Path       ripgrepData `json:"path"`
+		Lines      ripgrepData `json:"lines"`
+		LineNumber int         `json:"line_number"`
+		Submatches []struct {
+			Start int `json:"start"`
+			End   int `json:"end"`
+		} `json:"submatches"`
+	} `json:"data"`
+}
+
+// String returns the decoded data.
+func (d *ripgrepData) String() (string, error) {
+	if d.Text != nil {
+		return *d.Text, nil
+	}
+	if d.Bytes != nil {
+		b, err := base64.StdEncoding.DecodeString(*d.Bytes)
+		return string(b), err
+	}
+	return "", errors.New("missing text and bytes")
+}
+
+// Parse parses a JSON message of ripgrep's output.  Context lines (e.g., via
+// -C) are reported without spans.
+func (s *ripgrepSearcher) Parse(output string) (*searchResult, error) {
+	var event ripgrepEvent
+	if err := json.Unmarshal([]byte(output), &event); err != nil {
+		return nil, err
+	}
+	if event.Type != "match" && event.Type != "context" {
+		return nil, nil
+	}
+
+	file, err := event.Data.Path.String()
+	if err != nil {
+		return nil, fmt.Errorf("decoding path: %w", err)
+	}
+	content, err := event.Data.Lines.String()
+	if err != nil {
+		return nil, fmt.Errorf("decoding lines: %w", err)
+	}
+	content = strings.TrimSuffix(content, "\n")
+
+	res := &searchResult{
+		file:    file,
+		line:    strconv.Itoa(event.Data.LineNumber),
+		content: content,
+	}
+	for _, sub := range event.Data.Submatches {
+		res.spans = append(res.spans, []int{sub.Start, sub.End})
+	}
+	return res, nil
+}
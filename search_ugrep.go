@@ -0,0 +1,38 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+// ugrepSearcher searches with ugrep.
+type ugrepSearcher struct{}
+
+// Name returns the name of the backend.
+func (s *ugrepSearcher) Name() string {
+	return "ugrep"
+}
+
+// Detect returns true if ugrep is installed.
+func (s *ugrepSearcher) Detect(v *vgrep) bool {
+	return installed("ugrep")
+}
+
+// Command returns the ugrep command line to search for args.  File names are
+// terminated by a NUL byte and only the matched text is colored.
+func (s *ugrepSearcher) Command(args []string) []string {
+	cmd := []string{
+		"ugrep", "-rnI", "--with-filename", "--null", "--color=always",
+		"--colors=ms=01;31:mc=:sl=:cx=:fn=:ln=:cn=:bn=:se=",
+	}
+	return append(cmd, args...)
+}
+
+// Env returns nil to run ugrep in an empty environment.
+func (s *ugrepSearcher) Env() []string {
+	return nil
+}
+
+// Parse splits a line of ugrep's output.
+func (s *ugrepSearcher) Parse(output string) (*searchResult, error) {
+	return splitNul(output)
+}
@@ -0,0 +1,491 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// selectorOnlyRegexp matches inputs of the shell that start with a selector
+// rather than a command.
+var selectorOnlyRegexp = regexp.MustCompile(`^\s*([\d!$@/-]|[fd]:|file~|(all|last)\b)`)
+
+// selectorError is an error in the selectors, which points at the offending
+// token when printed.
+type selectorError struct {
+	input string
+	start int // byte offset of the token
+	end   int // byte offset after the token
+	msg   string
+}
+
+// Error returns the input with the offending token underlined followed by
+// the message.
+func (e *selectorError) Error() string {
+	width := e.end - e.start
+	if width < 1 {
+		width = 1
+	}
+	return fmt.Sprintf("%s\n%s%s %s", e.input, strings.Repeat(" ", e.start), strings.Repeat("^", width), e.msg)
+}
+
+// selectorToken is a part of the input of the selectors.
+type selectorToken struct {
+	text  string
+	start int // byte offset in the input
+}
+
+// trim returns the token without surrounding white space.
+func (t selectorToken) trim() selectorToken {
+	trimmed := strings.TrimLeft(t.text, " \t")
+	start := t.start + len(t.text) - len(trimmed)
+	return selectorToken{text: strings.TrimRight(trimmed, " \t"), start: start}
+}
+
+// split splits the token at each occurrence of sep.
+func (t selectorToken) split(sep string) []selectorToken {
+	var tokens []selectorToken
+	start := t.start
+	for _, text := range strings.Split(t.text, sep) {
+		tokens = append(tokens, selectorToken{text: text, start: start})
+		start += len(text) + len(sep)
+	}
+	return tokens
+}
+
+// selectorParser parses selectors.  Selectors are lists of terms separated by
+// commas, which can be combined with the set operators "&" (intersection),
+// "|" (union) and " - " (difference, which must be surrounded by white space
+// to distinguish it from ranges).  Operators are evaluated from left to right.
+// The terms are the following:
+//
+//	N        a single index
+//	N-M      a range of indices
+//	N-       all indices from N on
+//	-M       all indices up to M
+//	N-M:S    every S-th index of a range (also for open ranges)
+//	all      all indices
+//	last, $  the last index, also as a bound of ranges (e.g., "10-$")
+//	f:GLOB   all matches in files matching the glob (e.g., "f:pkg/*.go")
+//	d:DIR    all matches in files under the directory (e.g., "d:internal/")
+//	@N       all matches in the same file as index N
+//	/REGEX/  all matches whose content matches the regexp
+//	file~RE  all matches in files whose path matches the regexp
+//	!TERM    excludes the indices of TERM
+//
+// If all terms of a list are exclusions, they are excluded from all indices.
+type selectorParser struct {
+	input   string
+	matches [][]string
+	last    int // index of the last match
+}
+
+// errorf returns a selectorError pointing at token.
+func (p *selectorParser) errorf(token selectorToken, format string, args ...interface{}) error {
+	return &selectorError{input: p.input, start: token.start, end: token.start + len(token.text), msg: fmt.Sprintf(format, args...)}
+}
+
+// bound parses a bound of a range.  Empty bounds are set to open.
+func (p *selectorParser) bound(token selectorToken, open int) (int, error) {
+	token = token.trim()
+	switch token.text {
+	case "":
+		return open, nil
+	case "last", "$":
+		if p.last < 0 {
+			return 0, p.errorf(token, "no matches to select")
+		}
+		return p.last, nil
+	}
+	num, err := strconv.Atoi(token.text)
+	if err != nil || num < 0 {
+		return 0, p.errorf(token, "non-numeric selector %q", token.text)
+	}
+	if num > p.last {
+		return 0, p.errorf(token, "index %d out of range (%d, %d)", num, 0, p.last)
+	}
+	return num, nil
+}
+
+// term parses a single term except for exclusions and adds the selected
+// indices to selected.
+func (p *selectorParser) term(token selectorToken, selected map[int]bool) error {
+	switch {
+	case token.text == "all":
+		for i := 0; i <= p.last; i++ {
+			selected[i] = true
+		}
+		return nil
+
+	case strings.HasPrefix(token.text, "f:"):
+		glob := strings.TrimSpace(token.text[2:])
+		if glob == "" {
+			return p.errorf(token, "f: requires a glob")
+		}
+		rgx, err := globRegexp(glob)
+		if err != nil {
+			return p.errorf(token, "invalid glob %q", glob)
+		}
+		// Globs without a slash match the base name of files.
+		base := !strings.Contains(glob, "/")
+		p.selectFiles(selected, func(file string) bool {
+			if base {
+				file = path.Base(file)
+			}
+			return rgx.MatchString(file)
+		})
+		return nil
+
+	case strings.HasPrefix(token.text, "d:"):
+		dir := strings.TrimSpace(token.text[2:])
+		if dir == "" {
+			return p.errorf(token, "d: requires a directory")
+		}
+		dir = path.Clean(dir)
+		p.selectFiles(selected, func(file string) bool {
+			return dir == "." || strings.HasPrefix(file, dir+"/")
+		})
+		return nil
+
+	case strings.HasPrefix(token.text, "/"):
+		rgx, err := p.regexp(token, token.text[1:])
+		if err != nil {
+			return err
+		}
+		for i, m := range p.matches {
+			if rgx.MatchString(ansi.RemoveANSI(m[3])) {
+				selected[i] = true
+			}
+		}
+		return nil
+
+	case strings.HasPrefix(token.text, "file~"):
+		rgx, err := p.regexp(token, token.text[len("file~"):])
+		if err != nil {
+			return err
+		}
+		p.selectFiles(selected, rgx.MatchString)
+		return nil
+
+	case strings.HasPrefix(token.text, "@"):
+		idx, err := p.bound(selectorToken{text: token.text[1:], start: token.start + 1}, -1)
+		if err != nil {
+			return err
+		}
+		if idx < 0 {
+			return p.errorf(token, "@ requires an index")
+		}
+		file := path.Clean(p.matches[idx][1])
+		p.selectFiles(selected, func(f string) bool { return f == file })
+		return nil
+	}
+
+	step := 1
+	parts := token.split(":")
+	switch len(parts) {
+	case 1:
+	case 2:
+		stepToken := parts[1].trim()
+		num, err := strconv.Atoi(stepToken.text)
+		if err != nil || num < 1 {
+			return p.errorf(stepToken, "invalid step %q", stepToken.text)
+		}
+		step = num
+	default:
+		return p.errorf(token, "invalid range format %q", token.text)
+	}
+
+	bounds := parts[0].split("-")
+	switch len(bounds) {
+	case 1:
+		if bounds[0].trim().text == "" {
+			return p.errorf(token, "empty selector")
+		}
+		if len(parts) == 2 {
+			return p.errorf(token, "step requires a range")
+		}
+		idx, err := p.bound(bounds[0], 0)
+		if err != nil {
+			return err
+		}
+		selected[idx] = true
+	case 2:
+		if bounds[0].trim().text == "" && bounds[1].trim().text == "" {
+			return p.errorf(token, "range requires at least one bound")
+		}
+		from, err := p.bound(bounds[0], 0)
+		if err != nil {
+			return err
+		}
+		to, err := p.bound(bounds[1], p.last)
+		if err != nil {
+			return err
+		}
+		if from > to {
+			from, to = to, from
+		}
+		for i := from; i <= to; i += step {
+			selected[i] = true
+		}
+	default:
+		return p.errorf(token, "invalid range format %q", token.text)
+	}
+	return nil
+}
+
+// regexp compiles expr of token.  Expressions of "/REGEX/" terms must end with
+// a slash, which can be escaped as "\/" in the expression.
+func (p *selectorParser) regexp(token selectorToken, expr string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(token.text, "/") {
+		if len(expr) == 0 || expr[len(expr)-1] != '/' || strings.HasSuffix(expr, `\/`) {
+			return nil, p.errorf(token, "unterminated regexp")
+		}
+		expr = strings.ReplaceAll(expr[:len(expr)-1], `\/`, "/")
+	}
+	rgx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, p.errorf(token, "invalid regexp %q", expr)
+	}
+	return rgx, nil
+}
+
+// selectFiles adds the indices of all matches in files for which match
+// returns true to selected.
+func (p *selectorParser) selectFiles(selected map[int]bool, match func(string) bool) {
+	for i, m := range p.matches {
+		if match(path.Clean(m[1])) {
+			selected[i] = true
+		}
+	}
+}
+
+// globRegexp compiles the glob into a regexp matching entire paths.  "*" and
+// "?" do not match a slash, while "**" matches across directories.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var rgx strings.Builder
+	rgx.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if !strings.HasPrefix(glob[i:], "**") {
+				rgx.WriteString("[^/]*")
+				continue
+			}
+			i++
+			if strings.HasPrefix(glob[i+1:], "/") {
+				// "**/" matches zero or more directories.
+				i++
+				rgx.WriteString("(.*/)?")
+				continue
+			}
+			rgx.WriteString(".*")
+		case '?':
+			rgx.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %q", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			rgx.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			rgx.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	rgx.WriteString("$")
+	return regexp.Compile(rgx.String())
+}
+
+// scan splits the input into operands, which are lists of terms, and the
+// operators between them.  Regexps of "/REGEX/" terms may contain commas,
+// operators and white space, while the ones of "file~" terms end at the first
+// comma or white space.
+func (p *selectorParser) scan() ([][]selectorToken, []selectorToken, error) {
+	var operands [][]selectorToken
+	var operators []selectorToken
+	var terms []selectorToken
+
+	input := p.input
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' }
+	termStart, atStart := 0, true
+	endTerm := func(end int) {
+		terms = append(terms, selectorToken{text: input[termStart:end], start: termStart}.trim())
+		termStart = end + 1
+		atStart = true
+	}
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case atStart && (isSpace(c) || c == '!'):
+			continue
+
+		case atStart && c == '/':
+			end := i + 1
+			for ; end < len(input); end++ {
+				if input[end] == '/' && input[end-1] != '\\' {
+					break
+				}
+			}
+			if end == len(input) {
+				token := selectorToken{text: input[i:], start: i}.trim()
+				return nil, nil, p.errorf(token, "unterminated regexp")
+			}
+			i = end
+			atStart = false
+
+		case atStart && strings.HasPrefix(input[i:], "file~"):
+			for i+1 < len(input) && !isSpace(input[i+1]) && input[i+1] != ',' {
+				i++
+			}
+			atStart = false
+
+		case c == ',':
+			endTerm(i)
+
+		case c == '&' || c == '|' || (c == '-' && i > 0 && isSpace(input[i-1]) && (i+1 == len(input) || isSpace(input[i+1]))):
+			endTerm(i)
+			operands = append(operands, terms)
+			operators = append(operators, selectorToken{text: string(c), start: i})
+			terms = nil
+
+		default:
+			atStart = false
+		}
+	}
+	endTerm(len(input))
+	operands = append(operands, terms)
+	return operands, operators, nil
+}
+
+// list returns the indices selected by the terms of a list.
+func (p *selectorParser) list(terms []selectorToken) (map[int]bool, error) {
+	selected := make(map[int]bool)
+	excluded := make(map[int]bool)
+	onlyExclusions := true
+	for _, token := range terms {
+		if strings.HasPrefix(token.text, "!") {
+			token = selectorToken{text: token.text[1:], start: token.start + 1}.trim()
+			if err := p.term(token, excluded); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		onlyExclusions = false
+		if err := p.term(token, selected); err != nil {
+			return nil, err
+		}
+	}
+
+	if onlyExclusions {
+		for i := 0; i <= p.last; i++ {
+			selected[i] = true
+		}
+	}
+	for idx := range excluded {
+		delete(selected, idx)
+	}
+	return selected, nil
+}
+
+// parse parses the selectors and returns the selected indices.
+func (p *selectorParser) parse() ([]int, error) {
+	indices := []int{}
+	if strings.TrimSpace(p.input) == "" {
+		return indices, nil
+	}
+
+	operands, operators, err := p.scan()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := p.list(operands[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, operator := range operators {
+		operand, err := p.list(operands[i+1])
+		if err != nil {
+			return nil, err
+		}
+		switch operator.text {
+		case "&":
+			for idx := range selected {
+				if !operand[idx] {
+					delete(selected, idx)
+				}
+			}
+		case "|":
+			for idx := range operand {
+				selected[idx] = true
+			}
+		case "-":
+			for idx := range operand {
+				delete(selected, idx)
+			}
+		}
+	}
+
+	for idx := range selected {
+		indices = append(indices, idx)
+	}
+	if len(indices) == 0 {
+		// An empty list of indices refers to all matches.
+		return nil, errors.New("no matches selected")
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
+// parseSelectors parses input for vgrep selectors and returns the corresponding
+// indices as a sorted []int.
+func (v *vgrep) parseSelectors(input string) ([]int, error) {
+	p := selectorParser{input: input, matches: v.matches, last: len(v.matches) - 1}
+	return p.parse()
+}
+
+// joinSelectorArgs joins selectors passed as separate command-line arguments.
+// The arguments are joined without spaces, so an unquoted "0 - 4" remains a
+// range.  A standalone "-" that is not surrounded by bounds of a range is the
+// difference operator instead (e.g., "all - 3").
+func joinSelectorArgs(args []string) string {
+	var joined strings.Builder
+	for i, arg := range args {
+		if arg == "-" && (i == 0 || i+1 == len(args) || !isRangeBound(args[i-1], true) || !isRangeBound(args[i+1], false)) {
+			joined.WriteString(" - ")
+			continue
+		}
+		joined.WriteString(arg)
+	}
+	return joined.String()
+}
+
+// isRangeBound returns true if the last (or first) term of arg can be a bound
+// of a range.
+func isRangeBound(arg string, last bool) bool {
+	terms := strings.Split(arg, ",")
+	term := terms[0]
+	if last {
+		term = terms[len(terms)-1]
+	}
+	term, _, _ = strings.Cut(strings.TrimSpace(term), ":")
+	if term == "last" || term == "$" {
+		return true
+	}
+	num, err := strconv.Atoi(term)
+	return err == nil && num >= 0
+}
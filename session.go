@@ -0,0 +1,265 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nightlyone/lockfile"
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// sessionNameRegexp matches valid names of sessions, which are used as names
+// of directories.
+var sessionNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// checkSessionName returns an error if name is not a valid session name.
+func checkSessionName(name string) error {
+	if !sessionNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid session name %q: only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	return nil
+}
+
+// sessionPaths returns the directories of the cache and the lock of the
+// specified session.
+func sessionPaths(name string) (string, string) {
+	return filepath.Join(cacheDir(), "vgrep-sessions", name), filepath.Join(lockDir(), "sessions", name)
+}
+
+// isSessionCommand returns true if input is a session command (e.g.,
+// "session rename foo bar").
+func isSessionCommand(input string) bool {
+	return input == "session" || strings.HasPrefix(input, "session ")
+}
+
+// commandSession runs the session command with the specified arguments.
+func (v *vgrep) commandSession(args string) bool {
+	if err := v.runSessionCommand(args); err != nil {
+		fmt.Println(err)
+	}
+	return false
+}
+
+// runSessionCommand lists, renames, copies or deletes sessions according to
+// args (e.g., "rename foo bar").
+func (v *vgrep) runSessionCommand(args string) error {
+	fields := strings.Fields(args)
+	logrus.Debugf("runSessionCommand(%q)", fields)
+
+	if len(fields) == 0 {
+		return v.listSessions()
+	}
+	for _, name := range fields[1:] {
+		if err := checkSessionName(name); err != nil {
+			return err
+		}
+	}
+
+	// The running vgrep keeps using the cache and lock of its session, so
+	// renaming or deleting it would silently recreate it on the next write.
+	if (fields[0] == "rename" || fields[0] == "delete") && len(fields) > 1 && fields[1] == v.Session {
+		return fmt.Errorf("cannot %s the current session %q", fields[0], v.Session)
+	}
+
+	switch {
+	case fields[0] == "list" && len(fields) == 1:
+		return v.listSessions()
+	case fields[0] == "rename" && len(fields) == 3:
+		return copySession(fields[1], fields[2], true)
+	case fields[0] == "copy" && len(fields) == 3:
+		return copySession(fields[1], fields[2], false)
+	case fields[0] == "delete" && len(fields) == 2:
+		return deleteSession(fields[1])
+	}
+	return fmt.Errorf("session expects %q", "[list | rename OLD NEW | copy FROM TO | delete NAME]")
+}
+
+// listSessions prints the sessions along with their last search.
+func (v *vgrep) listSessions() error {
+	dirs, err := os.ReadDir(filepath.Join(cacheDir(), "vgrep-sessions"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, []string{"Session", "Matches", "Time", "Directory", "Query"})
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		name := dir.Name()
+		if name == v.Session {
+			name += " *"
+		}
+		row := []string{name, "", "", "", ""}
+
+		cache, _ := sessionPaths(dir.Name())
+		cache = filepath.Join(cache, "vgrep-go")
+		if info, err := os.Stat(cache); err == nil {
+			data, err := os.ReadFile(cache)
+			if err == nil {
+				var search *cacheData
+				search, err = decodeCache(data, info.ModTime())
+				if err == nil {
+					row[1] = strconv.Itoa(len(search.Matches))
+					row[2] = search.Created.Local().Format("2006-01-02 15:04:05")
+					row[3] = search.WorkDir
+					if search.Backend != "" {
+						row[4] = searchCommand(search.Backend, search.Args)
+					}
+				}
+			}
+			if err != nil {
+				logrus.Debugf("error reading cache of session %s: %v", dir.Name(), err)
+			}
+		}
+		toPrint = append(toPrint, row)
+	}
+
+	cw := colwriter.New(5)
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.MAGENTA, ansi.GREEN, ansi.BLUE, ansi.DEFAULT}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadRight, colwriter.PadLeft, colwriter.PadRight, colwriter.PadRight, colwriter.PadNone}
+	cw.UseLess = !v.NoLess
+
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+
+	return nil
+}
+
+// lockSession acquires the lock of the specified session.
+func lockSession(name string) (lockfile.Lockfile, error) {
+	_, dir := sessionPaths(name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	lock, err := lockfile.New(filepath.Join(dir, "cache-lock"))
+	if err != nil {
+		return "", err
+	}
+	return lock, waitForLock(lock)
+}
+
+// copySession copies the session from to the new session to.  The session
+// from is removed if rename is set.
+func copySession(from, to string, rename bool) error {
+	src, _ := sessionPaths(from)
+	dst, _ := sessionPaths(to)
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %q does not exist", from)
+		}
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("session %q already exists", to)
+	}
+
+	// Lock both sessions in the order of their names to avoid deadlocks
+	// with concurrent copies in the opposite direction.
+	names := []string{from, to}
+	sort.Strings(names)
+	var locks []lockfile.Lockfile
+	var err error
+	for _, name := range names {
+		var lock lockfile.Lockfile
+		if lock, err = lockSession(name); err != nil {
+			break
+		}
+		locks = append(locks, lock)
+	}
+	if err == nil {
+		if _, statErr := os.Stat(dst); statErr == nil {
+			err = fmt.Errorf("session %q already exists", to)
+		} else if rename {
+			err = os.Rename(src, dst)
+		} else {
+			err = copyDir(src, dst)
+		}
+	}
+	for _, lock := range locks {
+		if err := lock.Unlock(); err != nil {
+			panic(fmt.Sprintf("Error releasing lock file: %v", err))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if rename {
+		_, lockDir := sessionPaths(from)
+		return os.RemoveAll(lockDir)
+	}
+	return nil
+}
+
+// deleteSession deletes the specified session.
+func deleteSession(name string) error {
+	dir, lockDir := sessionPaths(name)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %q does not exist", name)
+		}
+		return err
+	}
+
+	lock, err := lockSession(name)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(dir)
+	if err := lock.Unlock(); err != nil {
+		panic(fmt.Sprintf("Error releasing lock file: %v", err))
+	}
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(lockDir)
+}
+
+// copyDir recursively copies the directory src to dst, which must not exist.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0700)
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+		return errors.New("unexpected file type of " + path)
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// sortKeyNames are the keys matches can be sorted by.
+var sortKeyNames = []string{"path", "line", "mtime", "count", "content"}
+
+// sortKey is a key to sort the matches by.
+type sortKey struct {
+	name string
+	desc bool
+}
+
+// parseSortKeys parses the comma-separated keys in spec.  Each key can be
+// suffixed with ":asc" (default) or ":desc" to set the order.
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key := sortKey{name: field}
+		if i := strings.Index(field, ":"); i >= 0 {
+			key.name = field[:i]
+			switch order := field[i+1:]; order {
+			case "asc":
+			case "desc":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort order %q (expected \"asc\" or \"desc\")", order)
+			}
+		}
+		valid := false
+		for _, name := range sortKeyNames {
+			valid = valid || key.name == name
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid sort key %q (expected one of %s)", key.name, strings.Join(sortKeyNames, ", "))
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no sort keys specified")
+	}
+	return keys, nil
+}
+
+// sortMatches sorts the matches by the keys and renumbers them.  Matches that
+// compare equal are sorted by path and line, which makes the order of the
+// matches independent of the order in which the search found them.
+func (v *vgrep) sortMatches(keys []sortKey) {
+	logrus.Debugf("sorting %d matches by %v", len(v.matches), keys)
+	keys = append(keys, sortKey{name: "path"}, sortKey{name: "line"})
+
+	count := make(map[string]int)
+	for _, m := range v.matches {
+		count[m[1]]++
+	}
+	mtime := make(map[string]int64)
+	for _, key := range keys {
+		if key.name != "mtime" {
+			continue
+		}
+		for file := range count {
+			if stamp, err := v.statFile(file); err == nil {
+				mtime[file] = stamp.ModTime.UnixNano()
+			}
+		}
+		break
+	}
+
+	compare := func(key sortKey, a, b []string) int {
+		switch key.name {
+		case "path":
+			return strings.Compare(a[1], b[1])
+		case "line":
+			x, _ := strconv.Atoi(a[2])
+			y, _ := strconv.Atoi(b[2])
+			return x - y
+		case "mtime":
+			x, y := mtime[a[1]], mtime[b[1]]
+			if x == y {
+				return 0
+			} else if x < y {
+				return -1
+			}
+			return 1
+		case "count":
+			return count[a[1]] - count[b[1]]
+		case "content":
+			return strings.Compare(ansi.RemoveANSI(a[3]), ansi.RemoveANSI(b[3]))
+		}
+		return 0
+	}
+
+	// Sort a copy to leave the previous matches untouched for undo.
+	matches := make([][]string, len(v.matches))
+	copy(matches, v.matches)
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, key := range keys {
+			c := compare(key, matches[i], matches[j])
+			if key.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	for i := range matches {
+		matches[i][0] = strconv.Itoa(i)
+	}
+	v.matches = matches
+}
+
+// commandSort sorts the matches by the keys in spec and writes them to the
+// cache.
+func (v *vgrep) commandSort(spec string) bool {
+	keys, err := parseSortKeys(spec)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	v.sortMatches(keys)
+	if err := v.updateCache(); err != nil {
+		fmt.Printf("error writing cache: %v\n", err)
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+)
+
+// relocateWindow is the number of lines before and after its previous line
+// that are searched for a match in a file that changed since the search.
+const relocateWindow = 100
+
+// matchLost is the status of a match that cannot be found in its file
+// anymore.
+const matchLost = "lost"
+
+// lostLabel marks lost matches when printing them.
+var lostLabel = ansi.Color("(not found)", ansi.RED, false)
+
+// fileStamp describes the state of a matched file at the time of the search.
+type fileStamp struct {
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+}
+
+// equal returns true if both stamps describe the same version of a file.  The
+// times are compared via Equal since their locations differ after decoding
+// the cache.
+func (s fileStamp) equal(other fileStamp) bool {
+	return s.ModTime.Equal(other.ModTime) && s.Size == other.Size
+}
+
+// statFile returns the stamp of the specified file of the matches.
+func (v *vgrep) statFile(file string) (fileStamp, error) {
+	if !path.IsAbs(file) {
+		file = path.Join(v.workDir, file)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+// stampFiles records the stamps of all matched files.
+func (v *vgrep) stampFiles() {
+	v.fileStamps = make(map[string]fileStamp)
+	for _, m := range v.matches {
+		if _, exists := v.fileStamps[m[1]]; exists {
+			continue
+		}
+		stamp, err := v.statFile(m[1])
+		if err != nil {
+			logrus.Debugf("error getting stamp of %s: %v", m[1], err)
+			continue
+		}
+		v.fileStamps[m[1]] = stamp
+	}
+}
+
+// isLost returns true if the match at the specified index cannot be found in
+// its file anymore.
+func (v *vgrep) isLost(index int) bool {
+	return isLost(v.matches[index])
+}
+
+// isLost returns true if the match m cannot be found in its file anymore.
+func isLost(m []string) bool {
+	return len(m) > 6 && m[6] == matchLost
+}
+
+// setLost sets whether the match at the specified index is lost.
+func (v *vgrep) setLost(index int, lost bool) {
+	if !lost && !v.isLost(index) {
+		return
+	}
+	for len(v.matches[index]) < 7 {
+		v.matches[index] = append(v.matches[index], "")
+	}
+	v.matches[index][6] = ""
+	if lost {
+		v.matches[index][6] = matchLost
+	}
+}
+
+// refreshMatches checks if the files of the matches at the specified indices
+// changed since the search.  The matches of changed files are relocated by
+// searching the nearby lines for their content, and are marked as lost if
+// they cannot be found.  Updated matches are written to the cache.
+func (v *vgrep) refreshMatches(indices []int) {
+	if v.fileStamps == nil {
+		// Caches of previous versions do not record stamps.
+		return
+	}
+
+	checked := make(map[string]bool)
+	updated := false
+	for _, idx := range indices {
+		file := v.matches[idx][1]
+		if checked[file] {
+			continue
+		}
+		checked[file] = true
+
+		old, exists := v.fileStamps[file]
+		if !exists {
+			continue
+		}
+		stamp, err := v.statFile(file)
+		if err == nil && stamp.equal(old) {
+			continue
+		}
+
+		logrus.Debugf("%s changed since the search", file)
+		var lines []string
+		if err == nil {
+			p, _, _ := v.fileLocation(idx)
+			var data []byte
+			data, err = os.ReadFile(p)
+			if err == nil {
+				lines = strings.SplitAfter(string(data), "\n")
+				if lines[len(lines)-1] == "" {
+					lines = lines[:len(lines)-1]
+				}
+			}
+		}
+		if err != nil {
+			logrus.Debugf("error reading %s: %v", file, err)
+		}
+		var inFile []int
+		for i := range v.matches {
+			if v.matches[i][1] == file {
+				inFile = append(inFile, i)
+			}
+		}
+		v.relocateMatches(inFile, lines)
+		v.fileStamps[file] = stamp
+		updated = true
+	}
+
+	if updated {
+		if err := v.updateCache(); err != nil {
+			logrus.Debugf("error writing cache: %v", err)
+		}
+	}
+}
+
+// relocateMatches searches lines, the current lines of a file, for the
+// matches at the specified indices in that file and updates their line
+// numbers.  All matches are relocated together: the offsets from their
+// previous lines that apply to the most matches are tried first, which keeps
+// their relative order, and a line is never assigned to matches of different
+// previous lines.  Matches that cannot be found nearby are marked as lost.
+func (v *vgrep) relocateMatches(indices []int, lines []string) {
+	previous := make(map[int]int) // previous line of each index
+	content := make(map[int]string)
+	found := func(idx, l int) bool {
+		return l >= 1 && l <= len(lines) && lineContent(lines[l-1]) == content[idx]
+	}
+
+	votes := make(map[int]int) // number of matches found at each offset
+	var toPlace []int
+	for _, idx := range indices {
+		line, err := strconv.Atoi(v.matches[idx][2])
+		if err != nil {
+			continue
+		}
+		previous[idx] = line
+		content[idx] = lineContent(ansi.RemoveANSI(v.matches[idx][3]))
+		toPlace = append(toPlace, idx)
+		for offset := -relocateWindow; offset <= relocateWindow; offset++ {
+			if found(idx, line+offset) {
+				votes[offset]++
+			}
+		}
+	}
+	sort.SliceStable(toPlace, func(i, j int) bool {
+		return previous[toPlace[i]] < previous[toPlace[j]]
+	})
+
+	offsets := make([]int, 0, len(votes))
+	for offset := range votes {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		a, b := offsets[i], offsets[j]
+		if votes[a] != votes[b] {
+			return votes[a] > votes[b]
+		}
+		if abs(a) != abs(b) {
+			return abs(a) < abs(b)
+		}
+		return a < b
+	})
+
+	claimed := make(map[int]int) // previous line of the matches at a line
+	placed := make(map[int]bool)
+	for _, offset := range offsets {
+		for _, idx := range toPlace {
+			line := previous[idx]
+			if placed[idx] || !found(idx, line+offset) {
+				continue
+			}
+			if owner, exists := claimed[line+offset]; exists && owner != line {
+				continue
+			}
+			claimed[line+offset] = line
+			placed[idx] = true
+			if offset != 0 {
+				logrus.Debugf("relocating match %d from line %d to %d", idx, line, line+offset)
+				v.matches[idx][2] = strconv.Itoa(line + offset)
+			}
+			v.setLost(idx, false)
+		}
+	}
+
+	for _, idx := range toPlace {
+		if !placed[idx] {
+			logrus.Debugf("match %d cannot be found anymore", idx)
+			v.setLost(idx, true)
+		}
+	}
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
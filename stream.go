@@ -0,0 +1,132 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// streamInterval is the interval in which matches are printed while a search
+// is running.  Collecting the matches of the first interval allows for
+// computing reasonable column sizes before the first screen is printed.
+const streamInterval = 100 * time.Millisecond
+
+// matchPrinter prints matches while the search is still running.  Matches are
+// added by the search and written in intervals by a timer.  Writing happens
+// outside of the search, such that a paused less(1) does not block it and the
+// results can be written to the cache once the search has finished.
+type matchPrinter struct {
+	v          *vgrep
+	cw         *colwriter.ColWriter
+	mutex      sync.Mutex      // protects pending and timer
+	writeMutex sync.Mutex      // serializes writes
+	pending    [][]string      // rows not yet printed
+	timer      *time.Timer     // non-nil if a write is scheduled
+	visited    map[string]bool // printed files in case of --files-with-matches
+}
+
+// newMatchPrinter returns a matchPrinter for v.  less(1) is only started
+// once there's a match to print.
+func (v *vgrep) newMatchPrinter() *matchPrinter {
+	logrus.Debug("printing matches while searching")
+	return &matchPrinter{v: v, visited: make(map[string]bool)}
+}
+
+// add adds the match at the specified index to p.  The match must not change
+// anymore.  Since v.matches grows during the search, the row is taken from
+// the match right away.
+func (p *matchPrinter) add(index int) {
+	row := []string{p.v.matches[index][1]}
+	if !p.v.FilesOnly {
+		row = p.v.matchRow(index)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pending = append(p.pending, row)
+	p.schedule()
+}
+
+// schedule schedules writing the pending rows.  The caller must hold p.mutex.
+func (p *matchPrinter) schedule() {
+	if p.timer != nil || len(p.pending) == 0 {
+		return
+	}
+	p.timer = time.AfterFunc(streamInterval, func() {
+		p.write()
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.timer = nil
+		p.schedule()
+	})
+}
+
+// close prints all remaining matches and waits for less(1) to exit.
+func (p *matchPrinter) close() {
+	p.mutex.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.mutex.Unlock()
+
+	p.write()
+
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	if p.cw != nil {
+		p.cw.Close()
+		p.cw = nil
+	}
+}
+
+// discard discards all rows that have not been printed yet.
+func (p *matchPrinter) discard() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pending = nil
+}
+
+// write writes all pending rows.
+func (p *matchPrinter) write() {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+
+	p.mutex.Lock()
+	rows := p.pending
+	p.pending = nil
+	p.mutex.Unlock()
+
+	if len(rows) == 0 {
+		return
+	}
+
+	v := p.v
+	if v.FilesOnly {
+		for _, row := range rows {
+			if !p.visited[row[0]] {
+				p.visited[row[0]] = true
+				fmt.Println(row[0])
+			}
+		}
+		return
+	}
+
+	if p.cw == nil {
+		p.cw = v.matchWriter(!v.NoLess)
+		p.cw.Open()
+		if p.cw.Headers {
+			// Size the header according to the first rows.
+			p.cw.ComputeSize(rows)
+			p.cw.Write([][]string{v.matchHeader()})
+		}
+	}
+	p.cw.Append(rows)
+	p.cw.Flush()
+}
@@ -0,0 +1,53 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+# The stubs in test/stubs print fixed matches in the output format of the
+# respective tool, including a file name with a colon.
+
+function setup() {
+	export PATH=$(pwd)/test/stubs:$PATH
+}
+
+@test "Search with ugrep" {
+	run_vgrep -d --backend=ugrep peanut 2>&1
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "ugrep -rnI --with-filename --null --color=always" ]]
+
+	run_vgrep --no-header --column
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "test/search_files/foobar.txt  2 6 zero peanut" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "test/search_files/foo:bar.txt 3 5 one peanut" ]]
+}
+
+@test "Search with ag" {
+	run_vgrep -d --backend=ag peanut 2>&1
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "ag --nogroup --numbers --filename --null --color" ]]
+
+	run_vgrep --no-header --column
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "test/search_files/foobar.txt  2 6 zero peanut" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "test/search_files/foo:bar.txt 3 5 one peanut" ]]
+}
+
+@test "Search with ack" {
+	run_vgrep -d --backend=ack peanut 2>&1
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "ack --noenv --nocolor --noheading --nobreak --no-filename --output=" ]]
+
+	# Both matches in line 3 are merged into one result.
+	run_vgrep --no-header --column
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "test/search_files/foobar.txt  2 6 zero peanut" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "test/search_files/foo:bar.txt 3 1 peanut and peanut" ]]
+}
+
+@test "Auto-detect ugrep before ag and git grep" {
+	run_vgrep -d --no-ripgrep peanut 2>&1
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "detected backend \\\"ugrep\\\"" ]]
+}
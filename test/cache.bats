@@ -0,0 +1,82 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	copy_search_files
+	mkdir -p $HOME/.cache
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Describe the loaded results" {
+	run_vgrep --native peanut test/search_files
+	[ "$status" -eq 0 ]
+
+	run_vgrep
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} == "results of native: \`peanut test/search_files\` in $(pwd), just now" ]]
+	[[ ${lines[1]} =~ "Index" ]]
+
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ! ${lines[0]} =~ "results of" ]]
+
+	# Commands do not print the header.
+	run_vgrep --show c0
+	[ "$status" -eq 0 ]
+	[[ ! ${lines[0]} =~ "results of" ]]
+}
+
+@test "Do not write the header into pipes" {
+	run_vgrep --native peanut test/search_files
+	[ "$status" -eq 0 ]
+
+	run sh -c "$VGREP 2>/dev/null"
+	[ "$status" -eq 0 ]
+	[[ ! ${lines[0]} =~ "results of" ]]
+	[[ ${lines[0]} =~ "Index" ]]
+
+	run_vgrep -l
+	[ "$status" -eq 0 ]
+	[[ ! ${output} =~ "results of" ]]
+}
+
+@test "Record the exit code of the search" {
+	run_vgrep --no-git peanut test/search_files does_not_exist
+	[ "$status" -eq 2 ]
+
+	run_vgrep
+	[[ ${lines[0]} =~ "(exit code 2)" ]]
+}
+
+@test "Migrate a cache of the previous format" {
+	printf '[["0","test/search_files/foobar.txt","2","zero peanut"],["%s"]]' "$(pwd)" > $HOME/.cache/vgrep-go
+	touch -d "3 hours ago" $HOME/.cache/vgrep-go
+
+	run_vgrep --global
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} == "results in $(pwd), 3h ago" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "zero peanut" ]]
+}
+
+@test "Keep a corrupted cache" {
+	echo "not a cache" > $HOME/.cache/vgrep-go
+
+	run_vgrep --global
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "corrupted cache (moved to $HOME/.cache/vgrep-go.corrupted)" ]]
+	[ -f $HOME/.cache/vgrep-go.corrupted ]
+	[ ! -f $HOME/.cache/vgrep-go ]
+}
+
+@test "Refuse caches of newer versions" {
+	echo '{"version":1000,"matches":[]}' > $HOME/.cache/vgrep-go
+
+	run_vgrep --global
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unsupported cache version 1000" ]]
+}
@@ -0,0 +1,53 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	export PATH=$BATS_TEST_DIRNAME/stubs:$PATH
+	setup_tmp
+	copy_search_files
+	OUT=$tmp/out
+	# Previous results to be kept when cancelling a search.
+	run_vgrep --native peanut test/search_files
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+# cancel_vgrep runs vgrep with a search that does not finish in time and sends
+# SIGINT.  The exit code is stored in $status.
+function cancel_vgrep() {
+	$VGREP -d --backend=ugrep "$@" slow_search > $OUT 2>&1 &
+	local pid=$!
+	sleep 1
+	kill -INT $pid
+	status=0
+	wait $pid || status=$?
+}
+
+@test "Cancel a search with Ctrl-C" {
+	cancel_vgrep
+	[ "$status" -eq 130 ]
+	grep -q "killing ugrep" $OUT
+	grep -q "search cancelled" $OUT
+	run pgrep -f "sleep 60"
+	[ "$status" -eq 1 ]
+
+	# The previous results are kept.
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+}
+
+@test "Cancel a search with Ctrl-C and --keep-partial" {
+	cancel_vgrep --keep-partial
+	[ "$status" -eq 130 ]
+	grep -q "search cancelled, keeping 1 matches" $OUT
+
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+	[[ ${lines[0]} =~ "test/search_files/foobar.txt" ]]
+}
@@ -0,0 +1,89 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf "peanut one\nbutter\npeanut two\n" > $tmp/a.txt
+	printf "peanut three\n" > $tmp/b.txt
+	run_vgrep --native --no-header peanut $tmp
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Edit matches in the editor" {
+	EDITOR="sed -i s/peanut/walnut/" run_vgrep -s edit
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "changed 3 line(s) in 2 file(s)" ]]
+
+	run cat $tmp/a.txt
+	[ "${lines[0]}" == "walnut one" ]
+	[ "${lines[1]}" == "butter" ]
+	[ "${lines[2]}" == "walnut two" ]
+	run cat $tmp/b.txt
+	[ "${lines[0]}" == "walnut three" ]
+}
+
+@test "Edit selected matches in the editor" {
+	EDITOR="sed -i s/peanut/walnut/" run_vgrep -s "e 1"
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "changed 1 line(s) in 1 file(s)" ]]
+
+	run grep -c walnut $tmp/a.txt $tmp/b.txt
+	[[ ${lines[0]} =~ ":1" ]]
+	[[ ${lines[1]} =~ ":0" ]]
+}
+
+@test "Edit matches of files changed since the search" {
+	sed -i s/one/zero/ $tmp/a.txt
+	EDITOR="sed -i s/peanut/walnut/" run_vgrep -s edit
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "refusing to change $tmp/a.txt:1: line changed since the search" ]]
+	[[ ${lines[1]} =~ "changed 2 line(s) in 2 file(s)" ]]
+
+	run cat $tmp/a.txt
+	[ "${lines[0]}" == "peanut zero" ]
+	[ "${lines[2]}" == "walnut two" ]
+}
+
+@test "Edit matches of symlinked and hardlinked files" {
+	mv $tmp/b.txt $tmp/real.txt
+	ln -s real.txt $tmp/b.txt
+	ln $tmp/real.txt $tmp/hard.txt
+	chmod 640 $tmp/a.txt
+	if is_root; then
+		chown 65534:65534 $tmp/a.txt
+	fi
+	owner=$(stat -c %u:%g $tmp/a.txt)
+	EDITOR="sed -i s/peanut/walnut/" run_vgrep -s edit
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "changed 3 line(s) in 2 file(s)" ]]
+
+	[ -L $tmp/b.txt ]
+	[ "$(stat -c %a $tmp/a.txt)" == "640" ]
+	[ "$(stat -c %u:%g $tmp/a.txt)" == "$owner" ]
+	run cat $tmp/real.txt $tmp/hard.txt
+	[ "${lines[0]}" == "walnut three" ]
+	[ "${lines[1]}" == "walnut three" ]
+}
+
+@test "Edit matches without changes" {
+	EDITOR="true" run_vgrep -s edit
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "no lines changed" ]]
+}
+
+@test "Edit matches with malformed lines" {
+	EDITOR="sed -i -e s/peanut/walnut/ -e s/^0.*/foo/ -e s/^1/7/" run_vgrep -s edit
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "ignoring malformed line 1: \"foo\"" ]]
+	[[ ${lines[1]} =~ "ignoring line 2: unknown match" ]]
+	[[ ${lines[2]} =~ "changed 1 line(s) in 1 file(s)" ]]
+
+	run cat $tmp/b.txt
+	[ "${lines[0]}" == "walnut three" ]
+}
@@ -11,22 +11,56 @@ load helpers
 	[[ ${lines[0]} =~ .*/vim ]]
 	args=(${lines[1]})
 	[[ ${args[0]} =~ .*/editor.bats ]]
-	[[ ${args[1]} == +5 ]]	# first occurence of 'test' is on line 5
+	# first occurence of 'test' is on line 5, column 2
+	[[ ${args[1]} == +call ]]
+	[[ ${args[2]} == "cursor(5,2)" ]]
 }
 
 
 @test "EDITORs that require line number before path" {
 	run_vgrep test test/editor.bats
 	[ "$status" -eq 0 ]
-	for cmd in emacs{,client} nano; do
+	for cmd in emacs{,client}; do
 	    export EDITOR=$cmd
 	    run_vgrep -s 0
 	    [ "$status" -eq 0 ]
 	    [[ ${lines[0]} =~ .*/$cmd ]]
 	    args=(${lines[1]})
-	    [[ ${args[0]} == +5 ]]
+	    [[ ${args[0]} == +5:2 ]]
 	    [[ ${args[1]} =~ .*/editor.bats ]]
 	done
+
+	export EDITOR=nano
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ .*/nano ]]
+	args=(${lines[1]})
+	[[ ${args[0]} == +5,2 ]]
+	[[ ${args[1]} =~ .*/editor.bats ]]
+}
+
+@test "EDITOR that requires path, line and column in one argument" {
+	run_vgrep test test/editor.bats
+	[ "$status" -eq 0 ]
+	export EDITOR=code
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ .*/code ]]
+	args=(${lines[1]})
+	[[ ${args[0]} == -g ]]
+	[[ ${args[1]} =~ .*/editor.bats:5:2 ]]
+}
+
+@test "EDITOR without column support" {
+	run_vgrep test test/editor.bats
+	[ "$status" -eq 0 ]
+	export EDITOR=gedit
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ .*/gedit ]]
+	args=(${lines[1]})
+	[[ ${args[0]} =~ .*/editor.bats ]]
+	[[ ${args[1]} == +5 ]]
 }
 
 @test "EDITOR command with options" {
@@ -38,7 +72,7 @@ load helpers
 	[[ ${lines[0]} =~ .*/emacs ]]
 	args=(${lines[1]})
 	[[ ${args[0]} == -nw ]]
-	[[ ${args[1]} == +5 ]]
+	[[ ${args[1]} == +5:2 ]]
 	[[ ${args[2]} =~ .*/editor.bats ]]
 }
 
@@ -63,6 +97,7 @@ load helpers
 	run_vgrep -s 0
 	[ "$status" -eq 0 ]
 	args=(${lines[1]})
-	[[ ${args[0]} == +5 ]]
-	[[ ${args[1]} =~ .*/editor.bats ]]
+	[[ ${args[0]} == +call ]]
+	[[ ${args[1]} == "cursor(5,2)" ]]
+	[[ ${args[2]} =~ .*/editor.bats ]]
 }
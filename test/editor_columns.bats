@@ -0,0 +1,34 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'äöü peanut\n' > $tmp/file.txt
+	run_vgrep --native peanut $tmp/file.txt
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "EDITORs that count the column in characters" {
+	unset EDITOR
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	args=(${lines[1]})
+	[[ ${args[2]} == "cursor(1,8)" ]]
+
+	export EDITOR=emacs
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	args=(${lines[1]})
+	[[ ${args[0]} == +1:5 ]]
+
+	export EDITOR=code
+	run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	args=(${lines[1]})
+	[[ ${args[1]} =~ :1:5$ ]]
+}
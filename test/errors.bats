@@ -40,3 +40,10 @@ function teardown() {
 	[[ ${lines[0]} =~ "test/search_files/wonly.txt: Permission denied" ]]
 	[[ ${lines[1]} =~ "bar baz" ]]
 }
+
+@test "Search with permission error (--native)" {
+	run_vgrep --no-header --native foo test/search_files
+	[ "$status" -eq 2 ]
+	[[ ${lines[0]} =~ "test/search_files/wonly.txt: permission denied" ]]
+	[[ ${lines[1]} =~ "bar baz" ]]
+}
@@ -0,0 +1,47 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'foo a\nb\nfoo c foo\n' > $tmp/a.txt
+	printf 'foo\nfoo\nx\nfoo\nfoo\n' > $tmp/b.txt
+	printf 'x\nfoo\n' > $tmp/c.txt
+	run_vgrep --native --sort path foo
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "List files with statistics" {
+	run_vgrep -s f
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") == "Index Matches Lines First Last File" ]]
+	[[ $(remove_ansi "${lines[1]}") == "   @0       2     2     1    3 a.txt" ]]
+	[[ $(remove_ansi "${lines[2]}") == "   @2       4     4     1    5 b.txt" ]]
+	[[ $(remove_ansi "${lines[3]}") == "   @6       1     1     2    2 c.txt" ]]
+}
+
+@test "List the files with the most matches" {
+	run_vgrep --no-header -s f2
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "b.txt" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "a.txt" ]]
+
+	run_vgrep --no-header --files-sort count -s f
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "b.txt" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "c.txt" ]]
+}
+
+@test "Open all matches of a listed file" {
+	EDITOR=echo run_vgrep -s s @2
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 4 ]]
+	[[ ${lines[0]} =~ "b.txt +1" ]]
+	[[ ${lines[3]} =~ "b.txt +5" ]]
+}
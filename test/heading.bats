@@ -0,0 +1,41 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'foo z\nfoo a\n' > $tmp/b.txt
+	printf 'foo m\n' > $tmp/a.txt
+	run_vgrep --native --sort path foo
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Print matches grouped by file" {
+	run_vgrep --heading
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "Index" ]]
+	[[ ! ${lines[1]} =~ "File" ]]
+	[[ $(remove_ansi "${lines[2]}") == "a.txt (1 match)" ]]
+	[[ $(remove_ansi "${lines[3]}") == "      0    1 foo m" ]]
+	[[ $(remove_ansi "${lines[4]}") == "b.txt (2 matches)" ]]
+	[[ $(remove_ansi "${lines[5]}") == "      1    1 foo z" ]]
+	[[ $(remove_ansi "${lines[6]}") == "      2    2 foo a" ]]
+}
+
+@test "Print matches grouped by file in the shell" {
+	run_vgrep --no-header -s ph 2,0
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 4 ]]
+	[[ $(remove_ansi "${lines[0]}") == "a.txt (1 match)" ]]
+	[[ $(remove_ansi "${lines[1]}") == "  0 1 foo m" ]]
+	[[ $(remove_ansi "${lines[2]}") == "b.txt (1 match)" ]]
+	[[ $(remove_ansi "${lines[3]}") == "  2 2 foo a" ]]
+
+	run bash -c "printf 'heading @1\nq\n' | $VGREP --interactive --no-less --no-header"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "b.txt (2 matches)" ]]
+}
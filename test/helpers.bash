@@ -22,6 +22,32 @@ function run_vgrep() {
 	fi
 }
 
+function remove_ansi() {
+	echo "$1" | sed 's/\x1b\[[0-9;]*m//g'
+}
+
 function is_root() {
     [ "$(id -u)" -eq 0 ]
 }
+
+# setup_tmp changes into a new temporary directory $tmp for the files of a
+# test and uses another one as $HOME, so the cache is not shared with other
+# tests.
+function setup_tmp() {
+	tmp_home=$(mktemp -d)
+	export HOME=$tmp_home
+	tmp=$(mktemp -d)
+	cd $tmp
+}
+
+# teardown_tmp removes the directories created by setup_tmp.
+function teardown_tmp() {
+	rm -rf "$tmp_home" "$tmp"
+}
+
+# copy_search_files copies test/search_files into $tmp, so tests can search
+# them by the same relative paths after setup_tmp.
+function copy_search_files() {
+	mkdir $tmp/test
+	cp -r $BATS_TEST_DIRNAME/search_files $tmp/test
+}
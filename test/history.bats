@@ -0,0 +1,67 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	copy_search_files
+	run_vgrep --native peanut test/search_files
+	[ "$status" -eq 0 ]
+	run_vgrep --native "zero peanut" test/search_files
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "List the search history" {
+	run_vgrep --history
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "Index" ]]
+	[[ ${lines[0]} =~ "Query" ]]
+	[[ ${lines[1]} =~ "native \"zero peanut\" test/search_files" ]]
+	[[ ${lines[1]} =~ "$(pwd)" ]]
+	[[ ${lines[2]} =~ "native peanut test/search_files" ]]
+}
+
+@test "Load a previous search" {
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+
+	run_vgrep --load 1 --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+
+	# The loaded search remains the current one.
+	run_vgrep --no-header -s "p 10"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "ten peanut" ]]
+}
+
+@test "Load a previous search in the interactive shell" {
+	run bash -c "printf 'history\nl 1\nq\n' | $VGREP --interactive --no-header"
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "native peanut test/search_files" ]]
+	[[ $(remove_ansi "$output") =~ "ten peanut" ]]
+}
+
+@test "Load a search out of range" {
+	run_vgrep --load 1000
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "error loading search \"1000\": index 1000 out of range" ]]
+
+	run_vgrep --load 0 peanut
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "--load cannot be combined with a new search" ]]
+}
+
+@test "Limit the size of the search history" {
+	for i in 1 2 3; do
+		VGREP_HISTORY_SIZE=2 run_vgrep --native peanut test/search_files
+	done
+	run_vgrep --history --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+}
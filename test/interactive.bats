@@ -58,3 +58,61 @@ EOF
 	[[ ${lines[0]} =~ "zero" ]]
 	[[ ${lines[1]} =~ "one" ]]
 }
+
+@test "Persist delete, keep and refine" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --show d 1-3
+	[ "$status" -eq 0 ]
+	run_vgrep --show k 0-3
+	[ "$status" -eq 0 ]
+	run_vgrep --show 'refine (zero|four)'
+	[ "$status" -eq 0 ]
+
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "1" ]]
+	[[ ${lines[1]} =~ "four" ]]
+
+	# Refining with a pattern that all matches match keeps them all.
+	run_vgrep --show 'refine peanut'
+	[ "$status" -eq 0 ]
+	run_vgrep --no-header
+	[[ ${#lines[*]} -eq 2 ]]
+}
+
+@test "Persist delete in the interactive shell" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --interactive --no-header << EOF
+d 0
+q
+EOF
+	[ "$status" -eq 0 ]
+
+	run_vgrep --no-header -s p 0
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "one" ]]
+}
+
+@test "Open the interactive shell on empty results" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --show d all
+	[ "$status" -eq 0 ]
+
+	run_vgrep
+	[ "$status" -eq 1 ]
+	[[ ${output} =~ "no matches" ]]
+
+	run_vgrep --interactive --no-header << EOF
+g peanut $FILE
+q
+EOF
+	[ "$status" -eq 0 ]
+	[[ ${lines[*]} =~ "zero" ]]
+
+	run_vgrep --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+}
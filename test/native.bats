@@ -0,0 +1,103 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+FILE=test/search_files/foobar.txt
+
+@test "Native search" {
+	run_vgrep --native --no-header peanut $FILE
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[10]} =~ "ten" ]]
+}
+
+@test "Native search with -w and -i" {
+	run_vgrep --native --no-header -w -i PEANUT $FILE
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "one" ]]
+}
+
+@test "Native search with multiple -e patterns" {
+	run_vgrep --native --no-header -e zero -e ten $FILE
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "ten" ]]
+}
+
+@test "Native search honours .gitignore" {
+	tmp=$(mktemp -d)
+	mkdir $tmp/sub $tmp/ignored_dir
+	echo "peanut" > $tmp/found.txt
+	echo "peanut" > $tmp/sub/found.txt
+	echo "peanut" > $tmp/ignored.log
+	echo "peanut" > $tmp/ignored_dir/file.txt
+	echo "peanut" > $tmp/sub/keep.log
+	printf "*.log\nignored_dir/\n" > $tmp/.gitignore
+	echo "!keep.log" > $tmp/sub/.gitignore
+	pushd $tmp
+	run_vgrep --native --no-header peanut
+	popd
+	rm -rf $tmp
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ${lines[@]} =~ "found.txt" ]]
+	[[ ${lines[@]} =~ "sub/found.txt" ]]
+	[[ ${lines[@]} =~ "sub/keep.log" ]]
+	[[ ! ${lines[@]} =~ "ignored" ]]
+}
+
+@test "Native search skips binary files unless -a is specified" {
+	run_vgrep --native --no-header NUL_BYTES test/search_files
+	[ "$status" -eq 1 ]
+	[[ ${#lines[*]} -eq 0 ]]
+
+	run_vgrep --native --no-header -a NUL_BYTES test/search_files
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "END_OF_LINE" ]]
+}
+
+@test "Native search with invalid regexp" {
+	run_vgrep --native "peanut(" $FILE
+	[ "$status" -eq 1 ]
+	[[ ${lines[@]} =~ "searching symbols failed" ]]
+}
+
+@test "Native search rejects flags that change the results" {
+	run_vgrep --native --invert-match peanut $FILE
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unsupported flag \"--invert-match\"" ]]
+
+	run_vgrep --native -x peanut $FILE
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unsupported flag \"-x\"" ]]
+
+	# Patterns are not basic regular expressions.
+	run_vgrep --native -G 'zero\|ten' $FILE
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unsupported flag \"-G\"" ]]
+
+	run_vgrep --native --basic-regexp peanut $FILE
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unsupported flag \"--basic-regexp\"" ]]
+
+	# Context lines do not change the results.
+	run_vgrep --native --no-header -C2 ten $FILE
+	[ "$status" -eq 0 ]
+	[[ ${output} =~ "ignoring unsupported flag" ]]
+	[[ ${output} =~ "ten" ]]
+}
+
+@test "Native search ignores context flags with separate values" {
+	run_vgrep --native --no-header --context 3 ten $FILE
+	[ "$status" -eq 0 ]
+	[[ ${output} =~ "ignoring unsupported flag \"--context\"" ]]
+	[[ ${output} =~ "ten" ]]
+
+	run_vgrep --native --no-header --after-context 1 --before-context=1 -A 2 ten $FILE
+	[ "$status" -eq 0 ]
+	[[ ${output} =~ "ten" ]]
+}
@@ -27,3 +27,9 @@ NUL_BYTE_FILE=test/search_files/nul_bytes.txt
 	[ "$status" -eq 0 ]
 	[[ ${lines[1]} =~ "END_OF_LINE" ]]
 }
+
+@test "Search file with NUL-bytes (--native)" {
+	run_vgrep --native -a NUL_BYTES $NUL_BYTE_FILE
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "END_OF_LINE" ]]
+}
@@ -0,0 +1,84 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	for p in a b; do
+		mkdir -p $tmp/$p/sub
+		git init -q $tmp/$p
+		echo "peanut in $p" > $tmp/$p/sub/file.txt
+	done
+	mkdir $tmp/c
+	echo "peanut in c" > $tmp/c/file.txt
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Keep the results of each project apart" {
+	cd $tmp/a
+	run_vgrep --native peanut
+	[ "$status" -eq 0 ]
+
+	cd $tmp/b
+	run_vgrep --native peanut
+	[ "$status" -eq 0 ]
+
+	cd $tmp/c
+	run_vgrep --native peanut
+	[ "$status" -eq 0 ]
+
+	# Subdirectories share the cache of the git tree.
+	cd $tmp/a/sub
+	run_vgrep --no-header -s "p 0"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "peanut in a" ]]
+
+	cd $tmp/b
+	run_vgrep --no-header -s "p 0"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "peanut in b" ]]
+
+	cd $tmp/c
+	run_vgrep --no-header -s "p 0"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "peanut in c" ]]
+
+	[ -d $HOME/.cache/vgrep-projects ]
+	[ ! -f $HOME/.cache/vgrep-go ]
+}
+
+@test "Use the global cache with --global" {
+	cd $tmp/a
+	run_vgrep --global --native peanut
+	[ "$status" -eq 0 ]
+	[ -f $HOME/.cache/vgrep-go ]
+
+	# The global results are not the ones of project b.
+	cd $tmp/b
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 0 ]]
+
+	run_vgrep --global --no-header -s "p 0"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "peanut in a" ]]
+}
+
+@test "Keep the history of each project apart" {
+	cd $tmp/a
+	run_vgrep --native peanut
+	[ "$status" -eq 0 ]
+
+	cd $tmp/b
+	run_vgrep --history --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 0 ]]
+
+	cd $tmp/a
+	run_vgrep --history --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+}
@@ -0,0 +1,148 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf "a\nb\nfoo(1)\nc\nd\ne\nf\ng\nh\ni\nfoo(2) foo(3)\nj\n" > $tmp/file.txt
+	run_vgrep --native --no-header foo $tmp/file.txt
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Replace with diff preview" {
+	run bash -c "echo y | $VGREP -s 'x/foo\((\d)\)/bar(\$1)/ 0-1'"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") == "--- a/$tmp/file.txt" ]]
+	[[ $(remove_ansi "${lines[1]}") == "+++ b/$tmp/file.txt" ]]
+	[[ $(remove_ansi "${lines[2]}") == "@@ -1,6 +1,6 @@" ]]
+	[[ $(remove_ansi "${lines[5]}") == "-foo(1)" ]]
+	[[ $(remove_ansi "${lines[6]}") == "+bar(1)" ]]
+	[[ $(remove_ansi "${lines[10]}") == "@@ -8,5 +8,5 @@" ]]
+	[[ $(remove_ansi "${lines[14]}") == "-foo(2) foo(3)" ]]
+	[[ $(remove_ansi "${lines[15]}") == "+bar(2) bar(3)" ]]
+	[[ ${lines[17]} =~ "replaced 2 line(s)" ]]
+
+	run grep -c bar $tmp/file.txt
+	[ "$output" -eq 2 ]
+	run grep -c foo $tmp/file.txt
+	[ "$output" -eq 0 ]
+}
+
+@test "Replace with a custom delimiter" {
+	run bash -c "echo y | $VGREP -s 'replace #foo#b\#z# 1'"
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "replaced 1 line(s)" ]]
+
+	run grep -c "b#z(2) b#z(3)" $tmp/file.txt
+	[ "$output" -eq 1 ]
+}
+
+@test "Replace without confirmation" {
+	run bash -c "echo n | $VGREP -s 'x/foo/bar/ 0'"
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "no lines changed" ]]
+
+	run grep -c foo $tmp/file.txt
+	[ "$output" -eq 2 ]
+}
+
+@test "Replace refuses to touch changed lines" {
+	sed -i 's/foo(1)/foo(4)/' $tmp/file.txt
+	run bash -c "echo y | $VGREP -s 'x/foo/bar/ 0-1'"
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "refusing to change $tmp/file.txt:3: line changed since the search" ]]
+	[[ ${lines[@]} =~ "replaced 1 line(s)" ]]
+
+	run grep -c "foo(4)" $tmp/file.txt
+	[ "$output" -eq 1 ]
+	run grep -c "bar(2) bar(3)" $tmp/file.txt
+	[ "$output" -eq 1 ]
+}
+
+@test "Replace through symlinks and keep the mode and owner of files" {
+	mv $tmp/file.txt $tmp/real.txt
+	ln -s real.txt $tmp/file.txt
+	chmod 640 $tmp/real.txt
+	if is_root; then
+		chown 65534:65534 $tmp/real.txt
+	fi
+	owner=$(stat -c %u:%g $tmp/real.txt)
+	run bash -c "echo y | $VGREP -s 'x/foo/bar/ 0'"
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "replaced 1 line(s)" ]]
+
+	[ -L $tmp/file.txt ]
+	[ "$(stat -c %a $tmp/real.txt)" == "640" ]
+	[ "$(stat -c %u:%g $tmp/real.txt)" == "$owner" ]
+	run grep -c bar $tmp/real.txt
+	[ "$output" -eq 1 ]
+}
+
+@test "Replace files the user cannot replace as a non-root user" {
+	if ! is_root; then
+		skip "requires root to switch to an unprivileged user"
+	fi
+	# A group-writable file of another user in a writable directory and a
+	# writable file in a directory the user cannot write to.
+	mkdir $tmp/shared $tmp/locked
+	echo "foo" > $tmp/shared/file.txt
+	echo "foo" > $tmp/locked/file.txt
+	chown 65534:65534 $tmp/shared $tmp/locked/file.txt
+	chown 0:65534 $tmp/shared/file.txt
+	chmod 664 $tmp/shared/file.txt
+	chmod 755 $tmp $tmp/locked
+	cp $VGREP $tmp/vgrep
+	mkdir $tmp/home
+	chown 65534:65534 $tmp/home
+
+	run setpriv --reuid=65534 --regid=65534 --clear-groups env HOME=$tmp/home \
+		bash -c "cd $tmp && ./vgrep --native foo shared locked > /dev/null && echo y | ./vgrep -s 'x/foo/bar/ 0-1'"
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "replaced 2 line(s)" ]]
+
+	[ "$(cat $tmp/shared/file.txt)" == "bar" ]
+	[ "$(cat $tmp/locked/file.txt)" == "bar" ]
+	[ "$(stat -c %u:%g $tmp/shared/file.txt)" == "0:65534" ]
+	[ "$(stat -c %a $tmp/shared/file.txt)" == "664" ]
+}
+
+@test "Replace refuses files changed before the confirmation" {
+	mkfifo $tmp/answer
+	$VGREP -s 'x/foo/bar/ 0' < $tmp/answer > $tmp/out 2>&1 &
+	exec 3> $tmp/answer
+	for i in $(seq 50); do
+		grep -q "Replace 1 line(s)?" $tmp/out && break
+		sleep 0.1
+	done
+	echo "k" >> $tmp/file.txt
+	echo y >&3
+	exec 3>&-
+	wait
+
+	run cat $tmp/out
+	[[ ${output} =~ "refusing to change $tmp/file.txt: file changed in the meantime" ]]
+	[[ ${output} =~ "replaced 0 line(s)" ]]
+	run grep -c foo $tmp/file.txt
+	[ "$output" -eq 2 ]
+	run tail -1 $tmp/file.txt
+	[ "$output" == "k" ]
+}
+
+@test "Replace with invalid expressions" {
+	run_vgrep -s 'x/foo/bar'
+	[[ ${lines[0]} =~ "unterminated replace expression \"/foo/bar\"" ]]
+
+	run_vgrep -s 'x/foo/bar/'
+	[[ ${lines[0]} =~ "replace requires specified selectors" ]]
+
+	run_vgrep -s 'x/foo(/bar/ 0'
+	[[ ${lines[0]} =~ "failed to compile 'foo(' as a regexp" ]]
+
+	run_vgrep -s 'replace afooabara 0'
+	[[ ${lines[0]} =~ "invalid delimiter 'a' of replace expression" ]]
+}
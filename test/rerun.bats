@@ -0,0 +1,60 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'a\nold_api(1)\nb\nold_api(2)\nc\n' > $tmp/file.txt
+	run_vgrep --native old_api
+	[ "$status" -eq 0 ]
+	printf 'a\nnew_api(1)\nold_api(2)\nb\nc\nold_api(3)\n' > $tmp/file.txt
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Rerun the last search" {
+	run_vgrep --rerun
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} == "rerun of native: \`old_api\` in $tmp: 1 new, 1 gone, 1 unchanged" ]]
+	[[ ${lines[1]} =~ "Index" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "    - file.txt    2 old_api(1)" ]]
+	[[ $(remove_ansi "${lines[3]}") =~ "   =0 file.txt    3 old_api(2)" ]]
+	[[ $(remove_ansi "${lines[4]}") =~ "   +1 file.txt    6 old_api(3)" ]]
+
+	# The new results are cached.
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "file.txt 6 old_api(3)" ]]
+}
+
+@test "Rerun the last search in its working directory" {
+	mkdir $tmp/sub
+	cd $tmp/sub
+	run_vgrep --session rerun --native old_api ..
+	[ "$status" -eq 0 ]
+
+	cd /
+	run_vgrep --session rerun --rerun --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "=0 ../file.txt 3 old_api(2)" ]]
+}
+
+@test "Rerun the last search in the interactive shell" {
+	run bash -c "printf 'rerun\nq\n' | $VGREP --interactive --no-less"
+	[ "$status" -eq 0 ]
+	[[ $output =~ "1 new, 1 gone, 1 unchanged" ]]
+}
+
+@test "Rerun errors" {
+	run_vgrep --rerun old_api
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "--rerun cannot be combined with a new search" ]]
+
+	run_vgrep --session empty --rerun
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "no search to rerun" ]]
+}
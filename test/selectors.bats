@@ -48,8 +48,10 @@ FILE=test/search_files/foobar.txt
 	run_vgrep peanut $FILE > /dev/null
 	run_vgrep --show c2 2-4
 	[ "$status" -eq 0 ]
-	[[ ${#lines[*]} -eq 18 ]]
-	[[ ${lines[15]} =~ "four" ]]
+	# The overlapping windows are merged into one block.
+	[[ ${#lines[*]} -eq 8 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "--- 2-4 test/search_files/foobar.txt" ]]
+	[[ $(remove_ansi "${lines[5]}") == "4 6 four peanuts" ]]
 }
 
 @test "Selectors: empty selection" {
@@ -72,14 +74,149 @@ FILE=test/search_files/foobar.txt
 	run_vgrep peanut $FILE > /dev/null
 	run_vgrep --no-header --show p 999
 	[ "$status" -eq 0 ]
-	[[ ${#lines[*]} -eq 1 ]]
-	[[ ${lines[@]} =~ "out of range" ]]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} == " 999" ]]
+	[[ ${lines[1]} == " ^^^ index 999 out of range (0, 10)" ]]
 }
 
 @test "Selectors: range out of range" {
 	run_vgrep peanut $FILE > /dev/null
 	run_vgrep --no-header --show p 0-999
 	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} == " 0-999" ]]
+	[[ ${lines[1]} == "   ^^^ index 999 out of range (0, 10)" ]]
+}
+
+@test "Selectors: index out of range in a list" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p 1,999
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} == " 1,999" ]]
+	[[ ${lines[1]} == "   ^^^ index 999 out of range (0, 10)" ]]
+}
+
+@test "Selectors: stepped range out of range" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p 0-999:10
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} == " 0-999:10" ]]
+	[[ ${lines[1]} == "   ^^^ index 999 out of range (0, 10)" ]]
+}
+
+@test "Selectors: open ranges" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p 8-
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ${lines[0]} =~ "eight" ]]
+
+	run_vgrep --no-header --show p -2
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ${lines[2]} =~ "two" ]]
+}
+
+@test "Selectors: last match" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p last
+	[ "$status" -eq 0 ]
 	[[ ${#lines[*]} -eq 1 ]]
-	[[ ${lines[@]} =~ "out of range" ]]
+	[[ ${lines[0]} =~ "ten" ]]
+
+	run_vgrep --no-header --show p '9-$'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+}
+
+@test "Selectors: stepped range" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p 0-10:3
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 4 ]]
+	[[ ${lines[1]} =~ "three" ]]
+	[[ ${lines[3]} =~ "nine" ]]
+}
+
+@test "Selectors: exclusions" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show 'p all,!3,!1-9'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[1]} =~ "ten" ]]
+
+	run_vgrep --no-header --show 'p !0-8'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "nine" ]]
+}
+
+@test "Selectors: point at invalid selectors" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p 1,x-3,5
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} == " 1,x-3,5" ]]
+	[[ ${lines[1]} == "   ^ non-numeric selector \"x\"" ]]
+
+	run_vgrep --no-header --show p 3:2
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "^^^ step requires a range" ]]
+}
+
+@test "Selectors: selectors without a command in the shell" {
+	run_vgrep peanut $FILE > /dev/null
+	run bash -c "printf '\$\nq\n' | EDITOR=echo $VGREP --interactive --no-less"
+	[ "$status" -eq 0 ]
+	[[ $output =~ "foobar.txt +12" ]]
+}
+
+@test "Selectors: content patterns" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p '/peanuts$/'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 9 ]]
+	[[ ${lines[0]} =~ "two" ]]
+
+	run_vgrep --no-header --show p 'file~foobar\.txt$'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+
+	run_vgrep --no-header --show p '/(unclosed'
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "^^^^^^^^^^ unterminated regexp" ]]
+}
+
+@test "Selectors: set operations" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p '/o/ & 0-3'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "one" ]]
+	[[ ${lines[2]} =~ "two" ]]
+
+	run_vgrep --no-header --show p '/[io]/ & f:*.txt - 1-3'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 6 ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "four" ]]
+
+	run_vgrep --no-header --show p '/two/ | /ten/'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[1]} =~ "ten" ]]
+}
+
+@test "Selectors: unquoted set difference" {
+	run_vgrep peanut $FILE > /dev/null
+	run_vgrep --no-header --show p all - 3
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 10 ]]
+	[[ ! ${output} =~ "three" ]]
+
+	run_vgrep --no-header --show p 0-4 - 1, 2
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
 }
@@ -0,0 +1,72 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	mkdir -p $tmp/pkg/sub $tmp/vendor/x $tmp/internal
+	echo "foo a" > $tmp/pkg/a.go
+	echo "foo b" > $tmp/pkg/sub/b.go
+	printf 'foo c\nfoo c2\n' > $tmp/vendor/x/c.go
+	echo "foo d" > $tmp/internal/d.txt
+	echo "foo e" > $tmp/e.go
+	run_vgrep --native foo
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Selectors: files matching a glob" {
+	run_vgrep --no-header --show p 'f:pkg/*.go'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "pkg/a.go" ]]
+
+	run_vgrep --no-header --show p 'f:*.go'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 5 ]]
+
+	run_vgrep --no-header --show p 'f:pkg/**'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+
+	run_vgrep --no-header --show p 'f:nothing'
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} == "no matches selected" ]]
+
+	run_vgrep --no-header --show p 'f:'
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "^^ f: requires a glob" ]]
+}
+
+@test "Selectors: files under a directory" {
+	run_vgrep --no-header --show p 'd:internal/'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "internal/d.txt" ]]
+
+	run_vgrep --no-header --show p '1,d: '
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ "^^ d: requires a directory" ]]
+
+	run_vgrep --show d 'f:vendor/**'
+	[ "$status" -eq 0 ]
+	run_vgrep --no-header --show p
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 4 ]]
+	[[ ! ${output} =~ "vendor" ]]
+}
+
+@test "Selectors: matches in the same file" {
+	EDITOR=echo run_vgrep --show s @5
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "vendor/x/c.go +1" ]]
+	[[ ${lines[1]} =~ "vendor/x/c.go +2" ]]
+
+	run_vgrep --no-header --show p 'all,!@4'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 4 ]]
+}
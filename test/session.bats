@@ -0,0 +1,101 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	copy_search_files
+	unset VGREP_SESSION
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Keep the results of each session apart" {
+	run_vgrep --session one --native peanut test/search_files
+	[ "$status" -eq 0 ]
+	VGREP_SESSION=two run_vgrep --native "zero peanut" test/search_files
+	[ "$status" -eq 0 ]
+
+	run_vgrep --session one --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+
+	VGREP_SESSION=two run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+
+	# The project's cache is not touched.
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 0 ]]
+}
+
+@test "List, rename, copy and delete sessions" {
+	run_vgrep --session one --native peanut test/search_files
+	[ "$status" -eq 0 ]
+
+	run_vgrep -s session copy one two
+	[ "$status" -eq 0 ]
+	run_vgrep -s "session rename one three"
+	[ "$status" -eq 0 ]
+
+	run_vgrep --session two --no-header -s session list
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "three" ]]
+	[[ ${lines[0]} =~ "native peanut test/search_files" ]]
+	[[ ${lines[1]} =~ "two *" ]]
+
+	run_vgrep --session three --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 11 ]]
+
+	run_vgrep -s session delete three
+	[ "$status" -eq 0 ]
+	run_vgrep --no-header -s session
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 1 ]]
+	[[ ${lines[0]} =~ "two" ]]
+}
+
+@test "Session errors" {
+	run_vgrep -s session delete one
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "session \"one\" does not exist" ]]
+
+	run_vgrep --session one --native peanut test/search_files
+	run_vgrep --session two --native peanut test/search_files
+	run_vgrep -s session rename one two
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "session \"two\" already exists" ]]
+
+	run_vgrep --session one -s session rename one three
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "cannot rename the current session \"one\"" ]]
+
+	run_vgrep --session one -s session delete one
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "cannot delete the current session \"one\"" ]]
+
+	run_vgrep -s session move one two
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "session expects" ]]
+
+	run_vgrep --session ../one
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "invalid session name \"../one\"" ]]
+
+	run_vgrep --session one --global
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "--session cannot be combined with --global" ]]
+}
+
+@test "Keep the history of the interactive shell of each session" {
+	run_vgrep --session one --native peanut test/search_files
+	run bash -c "printf 'p 1\nq\n' | $VGREP --session one --interactive --no-less"
+	[ "$status" -eq 0 ]
+	[[ $(cat $HOME/.cache/vgrep-sessions/one/vgrep-shell-history) == $'p 1\nq' ]]
+	[ ! -f $HOME/.cache/vgrep-sessions/two/vgrep-shell-history ]
+}
@@ -64,3 +64,29 @@ load helpers
 	[ "$status" -eq 0 ]
 	[[ ${lines[0]} =~ "vgrep command help: command[context lines] [selectors]" ]]
 }
+
+@test "Show context with different lines before and after" {
+	run_vgrep peanut test/search_files/foobar.txt
+	[ "$status" -eq 0 ]
+	run_vgrep -s c1,3 0,6
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 12 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "--- 0 test/search_files/foobar.txt" ]]
+	[[ $(remove_ansi "${lines[1]}") == "  1 foo bar baz" ]]
+	[[ $(remove_ansi "${lines[2]}") == "0 2 zero peanut" ]]
+	[[ $(remove_ansi "${lines[5]}") == "  5 three peanuts" ]]
+	[[ $(remove_ansi "${lines[6]}") =~ "--- 6 test/search_files/foobar.txt" ]]
+	[[ $(remove_ansi "${lines[8]}") == "6  8 six peanuts" ]]
+}
+
+@test "Show context of matches in one block" {
+	run_vgrep peanut test/search_files/foobar.txt
+	[ "$status" -eq 0 ]
+	run_vgrep -s c0,1 1,3,5
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 7 ]]
+	[[ $(remove_ansi "${lines[0]}") =~ "--- 1,3,5 test/search_files/foobar.txt" ]]
+	[[ $(remove_ansi "${lines[1]}") == "1 3 one peanut" ]]
+	[[ $(remove_ansi "${lines[2]}") == "  4 two peanuts" ]]
+	[[ $(remove_ansi "${lines[3]}") == "3 5 three peanuts" ]]
+}
@@ -21,7 +21,8 @@ load helpers
 
 	run_vgrep
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "results of " ]]
+	[[ ${lines[1]} =~ "0" ]]
 }
 
 @test "Simple search and --no-ripgrep" {
@@ -31,7 +32,8 @@ load helpers
 
 	run_vgrep
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "results of " ]]
+	[[ ${lines[1]} =~ "0" ]]
 }
 
 @test "Simple search and --no-git --no-ripgrep" {
@@ -41,7 +43,8 @@ load helpers
 
 	run_vgrep
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "results of " ]]
+	[[ ${lines[1]} =~ "0" ]]
 }
 
 @test "Simple search and --no-header" {
@@ -51,7 +54,8 @@ load helpers
 
 	run_vgrep
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "results of " ]]
+	[[ ${lines[1]} =~ "0" ]]
 }
 
 @test "Simple search and --no-less" {
@@ -61,7 +65,24 @@ load helpers
 
 	run_vgrep --no-less
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ "0" ]]
+	[[ ${lines[0]} =~ "results of " ]]
+	[[ ${lines[1]} =~ "0" ]]
+}
+
+@test "Simple search and --column" {
+	run_vgrep --column -w peanut test/search_files/foobar.txt
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "Column" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "2      6 zero peanut" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "3      5 one peanut" ]]
+
+	run_vgrep --column --backend=grep -w peanut test/search_files/foobar.txt
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") =~ "2      6 zero peanut" ]]
+
+	run_vgrep --column --native -w peanut test/search_files/foobar.txt
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") =~ "2      6 zero peanut" ]]
 }
 
 @test "Simple search and --files-with-matches" {
@@ -72,14 +93,44 @@ load helpers
 
 # Check that all grep tools are used when expected
 
+@test "Print matches while searching in a terminal" {
+	command -v script || skip "script(1) is not installed"
+	run script -qec "$VGREP -d --no-less --native peanut test/search_files" /dev/null
+	[ "$status" -eq 0 ]
+	[[ ${lines[@]} =~ "printing matches while searching" ]]
+	[[ ${lines[@]} =~ "test/search_files/foobar.txt" ]]
+	[[ ${lines[@]} =~ "found 11 matches" ]]
+}
+
+@test "Print matching files while searching in a terminal" {
+	command -v script || skip "script(1) is not installed"
+	run script -qec "$VGREP --no-less -l --native peanut test/search_files" /dev/null
+	[ "$status" -eq 0 ]
+	[ $(echo "$output" | grep -c "test/search_files/foobar.txt") -eq 1 ]
+}
+
 @test "Search with ripgrep" {
 	run_vgrep -d some_pattern 2>&1
-	[[ ${lines[@]} =~ "rg -0" ]]
+	[[ ${lines[@]} =~ "rg --json" ]]
+}
+
+@test "Search with ripgrep and a custom color configuration" {
+	config=$(mktemp)
+	printf -- "--color=always\n--colors=match:fg:blue\n--colors=path:fg:green\n" > $config
+	export RIPGREP_CONFIG_PATH=$config
+	run_vgrep --no-header -w peanut test/search_files/foobar.txt
+	unset RIPGREP_CONFIG_PATH
+	rm $config
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+	[[ ${lines[0]} =~ "test/search_files/foobar.txt" ]]
+	[[ ${lines[0]} =~ "zero" ]]
+	[[ ${lines[1]} =~ "one" ]]
 }
 
 @test "Search with git grep" {
 	run_vgrep -d --no-ripgrep some_pattern 2>&1
-	[[ ${lines[@]} =~ "git -c color.grep.match=red bold grep" ]]
+	[[ ${lines[@]} =~ "-c color.grep.match=red bold grep -z -In --color=always" ]]
 }
 
 @test "Search with classic grep" {
@@ -97,13 +148,34 @@ load helpers
 	[[ ${lines[@]} =~ "grep -ZHInr" ]]
 }
 
+@test "Search with the built-in search" {
+	run_vgrep -d --native some_pattern 2>&1
+	[[ ${lines[@]} =~ "nativeGrep(args=[some_pattern])" ]]
+}
+
+@test "Select backend with --backend" {
+	run_vgrep -d --backend=grep some_pattern 2>&1
+	[[ ${lines[@]} =~ "grep -ZHInr" ]]
+
+	run_vgrep -d --backend=native some_pattern 2>&1
+	[[ ${lines[@]} =~ "nativeGrep(args=[some_pattern])" ]]
+}
+
+@test "Select unknown backend with --backend" {
+	run_vgrep --backend=foo some_pattern
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "unknown backend \"foo\" (choose from rg, ugrep, ag, git, grep, ack, native)" ]]
+}
+
 # Other checks
 
-@test "Search with -C5 for context lines" {
-	run_vgrep -d -C5 peanut
+@test "Search with -C1 for context lines" {
+	run_vgrep --no-header -C1 "zero peanut" test/search_files/foobar.txt
 	[ "$status" -eq 0 ]
-	echo "${lines[@]}"
-	[[ ${lines[@]} =~ "level=debug msg=\"skipping line \\\"--\\\" (parse error: expected 2 but split into 1 items ([0]))\"" ]]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ${lines[0]} =~ "foo bar baz" ]]
+	[[ ${lines[1]} =~ "zero peanut" ]]
+	[[ ${lines[2]} =~ "one peanut" ]]
 }
 
 @test "Exit with 1 when a search has no matches" {
@@ -0,0 +1,63 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'foo z\nfoo a\n' > $tmp/b.txt
+	printf 'foo m\n' > $tmp/a.txt
+	printf 'foo q\nfoo r\nfoo s\n' > $tmp/c.txt
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Sort the results of a search" {
+	run_vgrep --native --no-header --sort count:desc,line:desc foo
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "0 c.txt 3 foo s" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "2 c.txt 1 foo q" ]]
+	[[ $(remove_ansi "${lines[3]}") =~ "3 b.txt 2 foo a" ]]
+	[[ $(remove_ansi "${lines[5]}") =~ "5 a.txt 1 foo m" ]]
+
+	# The order is written to the cache.
+	EDITOR=echo run_vgrep -s 4
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "b.txt +1" ]]
+}
+
+@test "Sort the cached results" {
+	run_vgrep --native foo
+	[ "$status" -eq 0 ]
+
+	run_vgrep --no-header --sort content
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "0 b.txt 2 foo a" ]]
+	[[ $(remove_ansi "${lines[5]}") =~ "5 b.txt 1 foo z" ]]
+
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") =~ "1 a.txt 1 foo m" ]]
+}
+
+@test "Sort in the interactive shell" {
+	run_vgrep --native foo
+	[ "$status" -eq 0 ]
+
+	run bash -c "printf 'sort path:desc\np 0\nundo\np 0\nq\n' | $VGREP --interactive --no-less --no-header"
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "0 c.txt 1 foo q" ]]
+	[[ ${lines[1]} =~ "undid \"sort path:desc\" (6 matches)" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "0 a.txt 1 foo m" ]]
+}
+
+@test "Sort errors" {
+	run_vgrep --native --sort size foo
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "invalid sort key \"size\"" ]]
+
+	run_vgrep --native --sort path:up foo
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "invalid sort order \"up\"" ]]
+}
@@ -0,0 +1,107 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	printf 'a\nfoo one\nb\nfoo two\nc\n' > $tmp/file.txt
+	run_vgrep --native foo
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Relocate matches in changed files" {
+	printf 'new\nnew\na\nfoo one\nb\nfoo two\nc\n' > $tmp/file.txt
+
+	run_vgrep --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "file.txt 4 foo one" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "file.txt 6 foo two" ]]
+
+	run_vgrep -s c1 1
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") == "  5 b" ]]
+	[[ $(remove_ansi "${lines[2]}") == "1 6 foo two" ]]
+
+	EDITOR=echo run_vgrep -s 1
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "file.txt +6" ]]
+
+	# The relocated matches are written to the cache.
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") =~ "file.txt 6 foo two" ]]
+}
+
+@test "Mark matches that cannot be found anymore" {
+	printf 'a\nfoo one\nb\nc\n' > $tmp/file.txt
+
+	run_vgrep --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "file.txt 2 foo one" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "file.txt 4 (not found) foo two" ]]
+
+	run_vgrep -s c1 1
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "--- 1 file.txt (not found)" ]]
+
+	# Lost matches are listed apart from the context of the others.
+	run_vgrep -s c1
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "--- 0 file.txt ---" ]]
+	[[ $(remove_ansi "${lines[2]}") =~ "0 2 foo one" ]]
+	[[ $(remove_ansi "${lines[4]}") =~ "--- 1 file.txt (not found)" ]]
+	[[ $(remove_ansi "${lines[5]}") =~ "1 4 foo two" ]]
+
+	EDITOR=echo run_vgrep -s 1
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "couldn't open index 1: match not found in file.txt anymore" ]]
+
+	# The match is found again once the line is restored.
+	printf 'a\nfoo one\nb\nfoo two\nc\n' > $tmp/file.txt
+	run_vgrep --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ ! ${output} =~ "not found" ]]
+}
+
+@test "Mark matches of deleted files" {
+	rm $tmp/file.txt
+
+	run_vgrep --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "(not found) foo one" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "(not found) foo two" ]]
+}
+
+@test "Do not check unchanged files again" {
+	TZ=UTC run_vgrep -d --no-header -s p
+	[ "$status" -eq 0 ]
+	[[ ! ${output} =~ "changed since the search" ]]
+	[[ $(remove_ansi "${lines[*]}") =~ "file.txt 2 foo one" ]]
+}
+
+@test "Relocate matches with identical content" {
+	printf 'x\nfoo\ny\nfoo\n' > $tmp/dup.txt
+	run_vgrep --native --sort path foo
+	[ "$status" -eq 0 ]
+	printf 'foo\ny\nfoo\n' > $tmp/dup.txt
+
+	run_vgrep --no-header -s p 0-1
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "dup.txt 1 foo" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "dup.txt 3 foo" ]]
+
+	EDITOR=echo run_vgrep -s 0
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "dup.txt +1" ]]
+
+	# A line is never assigned to two matches.
+	printf 'foo\ny\n' > $tmp/dup.txt
+	run_vgrep --no-header -s p 0-1
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "dup.txt 1 foo" ]]
+	[[ $(remove_ansi "${lines[1]}") =~ "dup.txt 3 (not found) foo" ]]
+}
@@ -0,0 +1,67 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+function setup() {
+	setup_tmp
+	mkdir -p $tmp/pkg/sub $tmp/internal
+	printf 'foo a\nfoo b\n' > $tmp/pkg/a.go
+	printf 'foo c\nfoo d\nfoo e\n' > $tmp/pkg/sub/b.go
+	printf 'foo f\n' > $tmp/internal/c.go
+	printf 'foo g\nfoo h\n' > $tmp/top.go
+	run_vgrep --native foo
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Print the tree of directories" {
+	run_vgrep -s t
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ "Matches" ]]
+	[[ ${lines[0]} =~ "Directory" ]]
+	[[ $(remove_ansi "${lines[1]}") == "      8 100.0% ." ]]
+	[[ $(remove_ansi "${lines[2]}") == "      1  12.5% ├── internal" ]]
+	[[ $(remove_ansi "${lines[3]}") == "      5  62.5% └── pkg" ]]
+	[[ $(remove_ansi "${lines[4]}") == "      3  37.5%     └── sub" ]]
+}
+
+@test "Print the tree sorted by count" {
+	run_vgrep --no-header --tree-sort count -s t
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[1]}") == "5  62.5% ├── pkg" ]]
+	[[ $(remove_ansi "${lines[3]}") == "1  12.5% └── internal" ]]
+
+	run_vgrep --tree-sort size
+	[ "$status" -eq 1 ]
+	[[ ${lines[0]} =~ "invalid --tree-sort \"size\"" ]]
+}
+
+@test "Print the tree with a limited depth" {
+	run_vgrep --no-header --depth 1 -s t
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ ! ${output} =~ "sub" ]]
+
+	run_vgrep --no-header -s t1
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+}
+
+@test "Print the tree of selected matches" {
+	run_vgrep --no-header -s t 'd:pkg/'
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 3 ]]
+	[[ $(remove_ansi "${lines[0]}") == "5 100.0% ." ]]
+	[[ $(remove_ansi "${lines[2]}") == "3  60.0%     └── sub" ]]
+}
+
+@test "Print the tree of empty results" {
+	run_vgrep -s d all
+	[ "$status" -eq 0 ]
+	run_vgrep --no-header -s t
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "0 0.0% ." ]]
+}
@@ -0,0 +1,81 @@
+#!/usr/bin/env bats -t
+
+load helpers
+
+FILE=test/search_files/foobar.txt
+
+function setup() {
+	setup_tmp
+	copy_search_files
+	run_vgrep --native peanut $FILE
+	[ "$status" -eq 0 ]
+}
+
+function teardown() {
+	teardown_tmp
+}
+
+@test "Undo and redo delete, keep and refine" {
+	run_vgrep --interactive --no-header << EOF
+d 0-4
+k 0,1
+r six
+undo
+undo
+p
+redo
+p
+q
+EOF
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "undid \"r six\" (2 matches)" ]]
+	[[ $(remove_ansi "$output") =~ "undid \"k 0,1\" (6 matches)" ]]
+	# The indices are restored.
+	[[ $(remove_ansi "$output") =~ "5 $FILE 12 ten peanuts" ]]
+	[[ $(remove_ansi "$output") =~ "redid \"k 0,1\" (2 matches)" ]]
+
+	# The restored results are written to the cache.
+	run_vgrep --no-header
+	[ "$status" -eq 0 ]
+	[[ ${#lines[*]} -eq 2 ]]
+}
+
+@test "Undo a new search" {
+	run_vgrep --interactive --no-header << EOF
+g -w peanut $FILE
+undo
+q
+EOF
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "undid \"g -w peanut $FILE\" (11 matches)" ]]
+}
+
+@test "List the steps to undo" {
+	run_vgrep --interactive --no-header << EOF
+undo list
+d 0
+r peanut
+k 0-4
+undo
+undo list
+q
+EOF
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "${lines[0]}") =~ "nothing to undo" ]]
+	[[ $(remove_ansi "$output") =~ "0 11 -> 10 d 0" ]]
+	[[ $(remove_ansi "$output") =~ "1  10 -> 5 k 0-4 (undone)" ]]
+	# Commands that do not change the results are not recorded.
+	[[ ! $(remove_ansi "$output") =~ "r peanut" ]]
+}
+
+@test "Nothing to redo" {
+	run_vgrep --interactive --no-header << EOF
+d 0
+undo
+redo
+redo
+q
+EOF
+	[ "$status" -eq 0 ]
+	[[ $(remove_ansi "$output") =~ "nothing to redo" ]]
+}
@@ -0,0 +1,138 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// treeNode is a directory in the tree of matches.
+type treeNode struct {
+	name     string
+	count    int // number of matches in the directory and below
+	children map[string]*treeNode
+}
+
+// child returns the child with the specified name and creates it if needed.
+func (n *treeNode) child(name string) *treeNode {
+	if n.children == nil {
+		n.children = make(map[string]*treeNode)
+	}
+	c, exists := n.children[name]
+	if !exists {
+		c = &treeNode{name: name}
+		n.children[name] = c
+	}
+	return c
+}
+
+// sortedChildren returns the children sorted by name or, if byCount is set,
+// by their number of matches in descending order.
+func (n *treeNode) sortedChildren(byCount bool) []*treeNode {
+	children := make([]*treeNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if byCount && children[i].count != children[j].count {
+			return children[i].count > children[j].count
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// buildTree returns the tree of the directories of the matches at the
+// specified indices.  The root is the working directory of the search.
+func (v *vgrep) buildTree(indices []int) *treeNode {
+	root := &treeNode{name: "."}
+	for _, idx := range indices {
+		root.count++
+		dir := path.Dir(path.Clean(v.matches[idx][1]))
+		if dir == "." {
+			continue
+		}
+		node := root
+		if strings.HasPrefix(dir, "/") {
+			node = node.child("/")
+			node.count++
+			dir = strings.TrimPrefix(dir, "/")
+		}
+		for _, name := range strings.Split(dir, "/") {
+			if name == "" {
+				continue
+			}
+			node = node.child(name)
+			node.count++
+		}
+	}
+	return root
+}
+
+// commandListTree prints the directories of the matches specified in indices
+// as a tree along with the number and percentage of matches in each of them.
+// Directories deeper than depth are collapsed into their parents unless depth
+// is 0.
+func (v *vgrep) commandListTree(indices []int, depth int) bool {
+	var err error
+
+	indices, err = v.checkIndices(indices)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return false
+	}
+
+	root := v.buildTree(indices)
+	byCount := v.TreeSort == "count"
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, []string{"Matches", "%", "Directory"})
+	}
+	row := func(n *treeNode, prefix string) []string {
+		percent := "0.0%"
+		if root.count > 0 {
+			percent = fmt.Sprintf("%.1f%%", float64(n.count)*100/float64(root.count))
+		}
+		return []string{strconv.Itoa(n.count), percent, prefix + n.name}
+	}
+
+	var walk func(n *treeNode, indent string, level int)
+	walk = func(n *treeNode, indent string, level int) {
+		if depth > 0 && level >= depth {
+			return
+		}
+		children := n.sortedChildren(byCount)
+		for i, c := range children {
+			connector, next := "├── ", "│   "
+			if i == len(children)-1 {
+				connector, next = "└── ", "    "
+			}
+			toPrint = append(toPrint, row(c, indent+connector))
+			walk(c, indent+next, level+1)
+		}
+	}
+	toPrint = append(toPrint, row(root, ""))
+	walk(root, "", 0)
+
+	cw := colwriter.New(3)
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.MAGENTA, ansi.GREEN}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadLeft, colwriter.PadNone}
+	cw.UseLess = !v.NoLess
+
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+
+	return false
+}
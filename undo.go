@@ -0,0 +1,140 @@
+package main
+
+// (c) 2015-2024 Valentin Rothberg <valentin@rothberg.email>
+//
+// Licensed under the terms of the GNU GPL License version 3.
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vrothberg/vgrep/internal/ansi"
+	"github.com/vrothberg/vgrep/internal/colwriter"
+)
+
+// undoStep is a command that changed the results.
+type undoStep struct {
+	command string     // input of the command
+	state   *cacheData // results before or, once undone, after the command
+	before  int        // number of matches before the command
+	after   int        // number of matches after the command
+}
+
+// snapshot returns a copy of the current results, which remains unchanged
+// by commands changing the matches in place.
+func (v *vgrep) snapshot() *cacheData {
+	state := v.cacheData()
+	matches := make([][]string, len(state.Matches))
+	for i, m := range state.Matches {
+		matches[i] = append([]string(nil), m...)
+	}
+	state.Matches = matches
+	if state.Files != nil {
+		files := make(map[string]fileStamp, len(state.Files))
+		for file, stamp := range state.Files {
+			files[file] = stamp
+		}
+		state.Files = files
+	}
+	return state
+}
+
+// sameMatches returns true if a and b are the same slice.
+func sameMatches(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// recordStep runs fn, which executes the command specified in input, and
+// records the previous results to allow for undoing the command if it changed
+// the matches.
+func (v *vgrep) recordStep(input string, fn func() bool) bool {
+	state := v.snapshot()
+	matches := v.matches
+	quit := fn()
+	if sameMatches(matches, v.matches) {
+		return quit
+	}
+	logrus.Debugf("recording step %q", input)
+	v.undoSteps = append(v.undoSteps, undoStep{command: input, state: state, before: len(state.Matches), after: len(v.matches)})
+	v.redoSteps = nil
+	return quit
+}
+
+// restoreStep makes the results of step the current ones and returns the
+// current results.
+func (v *vgrep) restoreStep(step undoStep) *cacheData {
+	current := v.snapshot()
+	v.useCache(step.state)
+	if err := v.updateCache(); err != nil {
+		fmt.Printf("error writing cache: %v\n", err)
+	}
+	return current
+}
+
+// commandUndo undoes the last command that changed the results.
+func (v *vgrep) commandUndo() bool {
+	if len(v.undoSteps) == 0 {
+		fmt.Println("nothing to undo")
+		return false
+	}
+	step := v.undoSteps[len(v.undoSteps)-1]
+	v.undoSteps = v.undoSteps[:len(v.undoSteps)-1]
+	step.state = v.restoreStep(step)
+	v.redoSteps = append(v.redoSteps, step)
+	fmt.Printf("undid %q (%d matches)\n", step.command, len(v.matches))
+	return false
+}
+
+// commandRedo redoes the last undone command.
+func (v *vgrep) commandRedo() bool {
+	if len(v.redoSteps) == 0 {
+		fmt.Println("nothing to redo")
+		return false
+	}
+	step := v.redoSteps[len(v.redoSteps)-1]
+	v.redoSteps = v.redoSteps[:len(v.redoSteps)-1]
+	step.state = v.restoreStep(step)
+	v.undoSteps = append(v.undoSteps, step)
+	fmt.Printf("redid %q (%d matches)\n", step.command, len(v.matches))
+	return false
+}
+
+// commandUndoList prints the commands that can be undone followed by the
+// ones that can be redone.
+func (v *vgrep) commandUndoList() bool {
+	if len(v.undoSteps)+len(v.redoSteps) == 0 {
+		fmt.Println("nothing to undo")
+		return false
+	}
+
+	var toPrint [][]string
+	if !v.NoHeader {
+		toPrint = append(toPrint, []string{"Step", "Matches", "Command"})
+	}
+	row := func(i int, step undoStep, suffix string) []string {
+		matches := strconv.Itoa(step.before) + " -> " + strconv.Itoa(step.after)
+		return []string{strconv.Itoa(i), matches, step.command + suffix}
+	}
+	for i, step := range v.undoSteps {
+		toPrint = append(toPrint, row(i, step, ""))
+	}
+	for i := len(v.redoSteps) - 1; i >= 0; i-- {
+		step := len(v.undoSteps) + len(v.redoSteps) - 1 - i
+		toPrint = append(toPrint, row(step, v.redoSteps[i], " (undone)"))
+	}
+
+	cw := colwriter.New(3)
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.GREEN, ansi.DEFAULT}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadLeft, colwriter.PadNone}
+
+	cw.Open()
+	cw.Write(toPrint)
+	cw.Close()
+
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -16,11 +17,13 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/pprof"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/shlex"
 	"github.com/jessevdk/go-flags"
@@ -39,15 +42,29 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 // cliArgs passed to go-flags
 type cliArgs struct {
+	Backend       string `long:"backend" description:"Use the specified search backend" value-name:"NAME"`
+	Column        bool   `long:"column" description:"Print the column of the first match"`
 	Debug         bool   `short:"d" long:"debug" description:"Verbose debug logging"`
+	Depth         int    `long:"depth" description:"Limit the tree to the specified depth" value-name:"N"`
 	FilesOnly     bool   `short:"l" long:"files-with-matches" description:"Print matching files only"`
+	FilesSort     string `long:"files-sort" description:"Sort the files by name or count" value-name:"KEY" default:"name"`
+	Global        bool   `long:"global" description:"Use the global cache instead of the one of the project"`
+	Heading       bool   `long:"heading" description:"Print the matches grouped by file"`
+	History       bool   `long:"history" description:"List the previous searches"`
 	Interactive   bool   `long:"interactive" description:"Enter interactive shell"`
+	KeepPartial   bool   `long:"keep-partial" description:"Keep the results of a search cancelled via Ctrl-C"`
+	Load          string `long:"load" description:"Load the results of the specified previous search" value-name:"N"`
 	MemoryProfile string `long:"memory-profile" description:"Write a memory profile to the specified path"`
+	Native        bool   `long:"native" description:"Use the built-in search (same as --backend=native)"`
 	NoGit         bool   `long:"no-git" description:"Use grep instead of git-grep"`
 	NoRipgrep     bool   `long:"no-ripgrep" description:"Do not use ripgrep"`
 	NoHeader      bool   `long:"no-header" description:"Do not print pretty headers"`
 	NoLess        bool   `long:"no-less" description:"Use stdout instead of less"`
+	Rerun         bool   `long:"rerun" description:"Run the search of the cached results again and compare the results"`
+	Session       string `long:"session" description:"Use the cache of the specified session (default: $VGREP_SESSION)" value-name:"NAME"`
 	Show          string `short:"s" long:"show" description:"Show specified matches or open shell" value-name:"SELECTORS"`
+	Sort          string `long:"sort" description:"Sort the matches by the specified keys (path, line, mtime, count, content)" value-name:"KEYS"`
+	TreeSort      string `long:"tree-sort" description:"Sort the tree by name or count" value-name:"KEY" default:"name"`
 	Version       bool   `short:"v" long:"version" description:"Print version number"`
 }
 
@@ -55,26 +72,42 @@ type cliArgs struct {
 type vgrep struct {
 	cliArgs
 	exitCode int
-	matches  [][]string
+	matches  [][]string // index, file, line, content, column, spans, status
+	printer  *matchPrinter
+	prompt   *liner.State // prompt of the shell
+	project  string       // key of the project's cache, empty if global
+	sortBy   []sortKey    // keys to sort the matches by
 	workDir  string
-	lock     lockfile.Lockfile
-	waiter   sync.WaitGroup
-}
 
-// the type of underlying grep program
-const (
-	BSDGrep = "BSD"
-	GNUGrep = "GNU"
-	GITGrep = "GIT"
-	RIPGrep = "RIP"
-)
+	// metadata of the search of the matches
+	query          []string
+	searchBackend  string
+	searchExitCode int
+	searchTime     time.Time
+	fileStamps     map[string]fileStamp
+
+	lock   lockfile.Lockfile
+	waiter sync.WaitGroup
+
+	// commands of the shell that changed the results
+	undoSteps []undoStep
+	redoSteps []undoStep
+
+	cancelMutex sync.Mutex
+	cancel      chan struct{} // closed to cancel the running search
+}
 
 var (
 	// set in the Makefile
 	version string
 
 	commands = [...]string{"print", "show", "context", "tree", "delete",
-		"keep", "refine", "files", "grep", "quit", "?"}
+		"keep", "refine", "files", "grep", "sort", "heading", "rerun", "replace", "edit", "history",
+		"load", "session", "undo", "redo", "quit", "?"}
+
+	// shortcuts of commands that do not start with their first letter or
+	// have none at all
+	shortcuts = map[string]string{"replace": "x", "sort": "", "heading": "ph", "rerun": "", "session": "", "redo": ""}
 )
 
 func main() {
@@ -84,11 +117,12 @@ func main() {
 	)
 
 	// vgrep must not be terminated with SIGINT since less pager must be
-	// terminated before vgrep. Ignore SIGINT.
+	// terminated before vgrep. Use SIGINT only to cancel searches.
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, os.Interrupt)
 	go func() {
 		for range sc {
+			v.interruptSearch()
 		}
 	}()
 
@@ -140,23 +174,117 @@ func main() {
 
 	logrus.Debugf("passed args: %s", args)
 
+	for flag, key := range map[string]string{"--files-sort": v.FilesSort, "--tree-sort": v.TreeSort} {
+		if key != "name" && key != "count" {
+			fmt.Fprintf(os.Stderr, "invalid %s %q (expected \"name\" or \"count\")\n", flag, key)
+			os.Exit(1)
+		}
+	}
+
+	if v.Sort != "" {
+		v.sortBy, err = parseSortKeys(v.Sort)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing --sort: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	v.workDir, err = resolvedWorkdir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error resolving working directory: %v\n", err)
+		os.Exit(1)
+	}
+	if v.Session != "" && v.Global {
+		fmt.Fprintf(os.Stderr, "--session cannot be combined with --global\n")
+		os.Exit(1)
+	}
+	if v.Session == "" && !v.Global {
+		v.Session = os.Getenv("VGREP_SESSION")
+	}
+	if v.Session != "" {
+		if err := checkSessionName(v.Session); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		logrus.Debugf("using cache of session %s", v.Session)
+	} else if !v.Global {
+		root := projectRoot(v.workDir)
+		v.project = projectKey(root)
+		logrus.Debugf("using cache of project %s", root)
+	}
+
 	// Load the cache if there's no new query, otherwise execute a new one.
 	err = v.makeLockFile()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error creating lock file: %v\n", err)
 		os.Exit(1)
 	}
-	v.workDir, err = resolvedWorkdir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error resolving working directory: %v\n", err)
-		os.Exit(1)
+
+	if v.History {
+		v.commandHistory()
+		os.Exit(0)
+	}
+
+	// Sessions can be managed without loading a cache.
+	if isSessionCommand(v.Show) && !v.Interactive {
+		show := strings.Join(append([]string{v.Show}, args...), " ")
+		if err := v.runSessionCommand(strings.TrimPrefix(show, "session")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if v.Load != "" {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "--load cannot be combined with a new search\n")
+			os.Exit(1)
+		}
+		index, err := strconv.Atoi(v.Load)
+		if err == nil {
+			err = v.loadHistory(index)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading search %q: %v\n", v.Load, err)
+			os.Exit(1)
+		}
+	}
+
+	if v.Rerun {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "--rerun cannot be combined with a new search\n")
+			os.Exit(1)
+		}
+		if v.Load == "" {
+			if err := v.loadCache(); err != nil {
+				if os.IsNotExist(err) {
+					fmt.Fprintf(os.Stderr, "no search to rerun\n")
+					os.Exit(1)
+				}
+				fmt.Fprintf(os.Stderr, "error loading cache: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		cancelled, err := v.rerun()
+		v.waiter.Wait()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if cancelled {
+			os.Exit(130)
+		}
+		if len(v.matches) == 0 && v.exitCode == 0 {
+			os.Exit(1)
+		}
+		os.Exit(v.exitCode)
 	}
 
 	haveToRunCommand := v.Show != "" || v.Interactive
 
 	// append additional args to the show command
 	if v.Show != "" && len(args) > 0 {
-		v.Show = fmt.Sprintf("%s %s", v.Show, strings.Join(args, ""))
+		v.Show = fmt.Sprintf("%s %s", v.Show, joinSelectorArgs(args))
 	}
 
 	if haveToRunCommand || len(args) == 0 {
@@ -169,13 +297,31 @@ func main() {
 			os.Exit(1)
 		}
 
-		if len(v.matches) == 0 {
+		// Commands and the interactive shell also work on empty results
+		// (e.g., after "delete all"), which allows for starting a new
+		// search.
+		if len(v.matches) == 0 && !haveToRunCommand {
+			v.printCacheHeader()
+			fmt.Fprintln(os.Stderr, "no matches")
 			if v.exitCode != 0 {
 				os.Exit(v.exitCode)
 			}
 			os.Exit(1)
 		}
 
+		if len(v.sortBy) > 0 {
+			v.sortMatches(v.sortBy)
+			if err := v.updateCache(); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing cache: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Describe the loaded results unless a command is run.
+		if v.Show == "" || v.Interactive {
+			v.printCacheHeader()
+		}
+
 		if haveToRunCommand {
 			v.commandParse()
 		} else {
@@ -185,38 +331,145 @@ func main() {
 		os.Exit(v.exitCode)
 	}
 
-	v.waiter.Add(1)
+	// Last resort, search and print all matches.
+	cancelled := v.search(args)
+	v.waiter.Wait()
+
+	if cancelled {
+		os.Exit(130)
+	}
+
+	if len(v.matches) == 0 && v.exitCode == 0 {
+		os.Exit(1)
+	}
+	os.Exit(v.exitCode)
+}
+
+// search greps with the specified args, writes the results to the cache and
+// prints them.  If stdout is a terminal, matches are printed while the search
+// is still running, unless they are sorted via --sort or grouped via
+// --heading.  The search can be cancelled via SIGINT, in which case the
+// partial results are discarded unless --keep-partial is specified.  search
+// returns true if the search has been cancelled.
+func (v *vgrep) search(args []string) bool {
+	return v.runSearch(args, true)
+}
+
+// runSearch is the implementation of search.  The matches are only printed if
+// print is set.
+func (v *vgrep) runSearch(args []string, print bool) bool {
+	if print && len(v.sortBy) == 0 && !v.Heading && term.IsTerminal(int(os.Stdout.Fd())) {
+		v.printer = v.newMatchPrinter()
+	}
+
+	previous := v.cacheData()
+	v.startSearch()
 	v.grep(args)
+	cancelled := v.stopSearch()
+	v.searchExitCode = v.exitCode
+
+	if cancelled && !v.KeepPartial {
+		if v.printer != nil {
+			v.printer.discard()
+			v.printer.close()
+			v.printer = nil
+		}
+		v.useCache(previous)
+		fmt.Fprintln(os.Stderr, "search cancelled")
+		return true
+	}
+	if len(v.sortBy) > 0 {
+		v.sortMatches(v.sortBy)
+	}
+	v.stampFiles()
+
+	v.waiter.Add(1)
 	v.cacheWrite() // this runs in the background
 
-	if len(v.matches) == 0 {
+	if v.printer != nil {
+		v.printer.close()
+		v.printer = nil
+	} else if print && len(v.matches) > 0 {
+		// Printing may relocate the matches of files that changed in
+		// the meantime, which updates the cache as well.
 		v.waiter.Wait()
-		if v.exitCode != 0 {
-			os.Exit(v.exitCode)
-		}
-		os.Exit(1)
+		v.commandPrintMatches([]int{})
 	}
 
-	// Last resort, print all matches.
-	v.commandPrintMatches([]int{})
-	v.waiter.Wait()
-	os.Exit(v.exitCode)
+	if cancelled {
+		fmt.Fprintf(os.Stderr, "search cancelled, keeping %d matches\n", len(v.matches))
+	}
+	return cancelled
 }
 
 // runCommand executes the program specified in args and returns the stdout as
 // a line-separated []string.
-func (v *vgrep) runCommand(args []string, env string) ([]string, error) {
+func (v *vgrep) runCommand(args []string, env []string) ([]string, error) {
+	var lines []string
+	err := v.streamCommand(args, env, func(line string) {
+		lines = append(lines, line)
+	})
+	return lines, err
+}
+
+// streamCommand executes the program specified in args and passes each line
+// of its stdout to fn as soon as it has been read.
+func (v *vgrep) streamCommand(args []string, env []string, fn func(string)) error {
 	var cmd *exec.Cmd
-	var sout, serr bytes.Buffer
+	var serr bytes.Buffer
 
 	logrus.Debugf("runCommand(args=%s, env=%s)", args, env)
 
 	cmd = exec.Command(args[0], args[1:]...)
-	cmd.Stdout = &sout
 	cmd.Stderr = &serr
-	cmd.Env = []string{env}
+	cmd.Env = append([]string{}, env...)
+	setProcessGroup(cmd)
 
-	err := cmd.Run()
+	if v.searchCancelled() {
+		return nil
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		logrus.Debugf("error running command: %v", err)
+		return err
+	}
+
+	// Kill the command along with its children when the search is
+	// cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-v.cancelled():
+			logrus.Debugf("killing %s", args[0])
+			if err := killProcessGroup(cmd); err != nil {
+				logrus.Debugf("error killing %s: %v", args[0], err)
+			}
+		case <-done:
+		}
+	}()
+
+	reader := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Only pass complete lines.
+			if err != io.EOF {
+				readErr = err
+			}
+			break
+		}
+		fn(strings.TrimSuffix(line, "\n"))
+	}
+
+	err = cmd.Wait()
+	if v.searchCancelled() {
+		return nil
+	}
 	if err != nil {
 		logrus.Debugf("error running command: %v", err)
 
@@ -232,16 +485,18 @@ func (v *vgrep) runCommand(args []string, env string) ([]string, error) {
 			err = nil
 		}
 	}
+	if err == nil {
+		err = readErr
+	}
 
-	slice := strings.Split(sout.String(), "\n")
-	return slice[:len(slice)-1], err
+	return err
 }
 
 // insideGitTree returns true if the current working directory is inside a git
 // tree.
 func (v *vgrep) insideGitTree() bool {
 	cmd := []string{"git", "rev-parse", "--is-inside-work-tree"}
-	out, _ := v.runCommand(cmd, "")
+	out, _ := v.runCommand(cmd, nil)
 	inside := false
 
 	if len(out) > 0 && out[0] == "true" {
@@ -252,43 +507,6 @@ func (v *vgrep) insideGitTree() bool {
 	return inside
 }
 
-func (v *vgrep) isOpenBSD() bool {
-	return runtime.GOOS == "openbsd"
-}
-
-// ripgrepInstalled returns true if ripgrep is installed
-func (v *vgrep) ripgrepInstalled() bool {
-	out, err := exec.LookPath("rg")
-	if err != nil {
-		logrus.Debug("error checking if ripgrep is installed")
-	}
-	installed := false
-
-	if len(out) > 0 {
-		installed = true
-	}
-
-	logrus.Debugf("ripgrepInstalled() -> %v", installed)
-	return installed
-}
-
-func (v *vgrep) getGrepType() string {
-	out, _ := v.runCommand([]string{"grep", "--version"}, "")
-	if len(out) == 0 {
-		return ""
-	}
-	versionString := out[0]
-	// versionString = "grep (BSD grep) 2.5.1-FreeBSD"
-	// versionString = "grep (BSD grep, GNU compatible) 2.6.0-FreeBSD"
-	versionRegex := regexp.MustCompile(`\(([[:alpha:]]+) grep`)
-	// versionRegex matches to ["(BSD grep)", "BSD"], return "BSD"
-	submatch := versionRegex.FindStringSubmatch(versionString)
-	if len(submatch) < 2 {
-		return ""
-	}
-	return submatch[1]
-}
-
 // isVscode checks if the terminal is running inside of vscode.
 func isVscode() bool {
 	return os.Getenv("TERM_PROGRAM") == "vscode"
@@ -299,114 +517,6 @@ func isGoland() bool {
 	return strings.Contains(os.Getenv("TERMINAL_EMULATOR"), "JetBrains")
 }
 
-// grep (git) greps with the specified args and stores the results in v.matches.
-func (v *vgrep) grep(args []string) {
-	var cmd []string
-	var env string
-	var greptype string // can have values , GIT, RIP, GNU, BSD
-
-	if v.ripgrepInstalled() && !v.NoRipgrep {
-		cmd = []string{
-			"rg", "-0", "--colors=path:none", "--colors=line:none",
-			"--color=always", "--no-heading", "--line-number",
-			"--with-filename",
-		}
-		cmd = append(cmd, args...)
-		greptype = RIPGrep
-		config := os.Getenv("RIPGREP_CONFIG_PATH")
-		if len(config) != 0 {
-			env = "RIPGREP_CONFIG_PATH=" + config
-		}
-	} else if v.insideGitTree() && !v.NoGit {
-		env = "HOME="
-		cmd = []string{
-			"git", "-c", "color.grep.match=red bold",
-			"grep", "-z", "-In", "--color=auto",
-		}
-		cmd = append(cmd, args...)
-		greptype = GITGrep
-	} else if v.isOpenBSD() && v.getGrepType() == "" {
-		// grep --version = "grep version 0.9"
-		cmd = []string{"grep", "-ZHInr"}
-		cmd = append(cmd, args...)
-		greptype = BSDGrep
-	} else {
-		env = "GREP_COLORS='ms=01;31:mc=:sl=:cx=:fn=:ln=:se=:bn='"
-		cmd = []string{"grep", "-ZHInr", "--color=always"}
-		cmd = append(cmd, args...)
-		greptype = v.getGrepType()
-	}
-	output, err := v.runCommand(cmd, env)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "searching symbols failed: %v\n", err)
-		os.Exit(1)
-	}
-	v.matches = make([][]string, len(output))
-	i := 0
-	for _, m := range output {
-		file, line, content, err := v.splitMatch(m, greptype)
-		if err != nil {
-			logrus.Debugf("skipping line %q (parse error: %v)", m, err)
-			continue
-		}
-		v.matches[i] = make([]string, 4)
-		v.matches[i][0] = strconv.Itoa(i)
-		v.matches[i][1] = file
-		v.matches[i][2] = line
-		v.matches[i][3] = content
-		i++
-	}
-
-	logrus.Debugf("found %d matches", len(v.matches))
-}
-
-// splitMatch splits match into its file, line and content.  The format of
-// match varies depending if it has been produced by grep or git-grep.
-func (v *vgrep) splitMatch(match string, greptype string) (file, line, content string, err error) {
-	if greptype == RIPGrep {
-		// remove default color ansi escape codes from ripgrep's output
-		match = strings.Replace(match, "\x1b[0m", "", 4)
-	}
-	var separator []byte
-	switch greptype {
-	case BSDGrep:
-		separator = []byte(":")
-	case GITGrep, GNUGrep, RIPGrep:
-		separator = []byte{0}
-	}
-	switch greptype {
-	case BSDGrep, GITGrep:
-		spl := bytes.SplitN([]byte(match), separator, 3)
-		if len(spl) < 3 {
-			err = fmt.Errorf("expected %d but split into %d items (%v)", 3, len(spl), separator)
-			return
-		}
-		file, line, content = string(spl[0]), string(spl[1]), string(spl[2])
-	case GNUGrep, RIPGrep:
-		spl := bytes.SplitN([]byte(match), separator, 2)
-		if len(spl) < 2 {
-			err = fmt.Errorf("expected %d but split into %d items (%v)", 2, len(spl), separator)
-			return
-		}
-		splline := bytes.SplitN(spl[1], []byte(":"), 2)
-		if len(splline) != 2 {
-			// Fall back to "-" which is used when displaying
-			// context lines.
-			splline = bytes.SplitN(spl[1], []byte("-"), 2)
-		}
-		if len(splline) == 2 {
-			file, line, content = string(spl[0]), string(splline[0]), string(splline[1])
-			return
-		}
-		err = fmt.Errorf("unexpected input")
-		return
-	default:
-		err = fmt.Errorf("unknown grep type %q", greptype)
-		return
-	}
-	return
-}
-
 // getEditor returns the EDITOR environment variable (default="vim").
 func (v *vgrep) getEditor() []string {
 	editor, err := shlex.Split(os.Getenv("EDITOR"))
@@ -442,12 +552,10 @@ func (v *vgrep) getEditorLineFlagReversed() bool {
 func (v *vgrep) makeLockFile() error {
 
 	var err error
-	var lockdir string
 
-	if runtime.GOOS == "windows" {
-		lockdir = filepath.Join(os.Getenv("LOCALAPPDATA"), "vgrep")
-	} else {
-		lockdir = filepath.Join(os.Getenv("HOME"), ".local/share/vgrep")
+	lockdir := lockDir()
+	if _, scope := v.cacheScope(); scope != "" {
+		lockdir = filepath.Join(lockdir, scope)
 	}
 	exists := true
 
@@ -470,7 +578,12 @@ func (v *vgrep) makeLockFile() error {
 
 // Try to acquire the lock file for the cache
 func (v *vgrep) acquireLock() error {
-	for err := v.lock.TryLock(); err != nil; err = v.lock.TryLock() {
+	return waitForLock(v.lock)
+}
+
+// waitForLock acquires the specified lock file and waits for it if it's busy.
+func waitForLock(lock lockfile.Lockfile) error {
+	for err := lock.TryLock(); err != nil; err = lock.TryLock() {
 		// If the lock is busy, wait for it, otherwise error out
 		if err != lockfile.ErrBusy {
 			return err
@@ -481,16 +594,39 @@ func (v *vgrep) acquireLock() error {
 	return nil
 }
 
-// cachePath returns the path to the user-specific vgrep cache.
-func (v *vgrep) cachePath() (string, error) {
-
-	var cache string
+// lockDir returns the directory of vgrep's lock files.
+func lockDir() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "vgrep")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local/share/vgrep")
+}
 
+// cacheDir returns the directory of vgrep's caches.
+func cacheDir() string {
 	if runtime.GOOS == "windows" {
-		cache = filepath.Join(os.Getenv("LOCALAPPDATA"), "vgrep-cache/")
-	} else {
-		cache = filepath.Join(os.Getenv("HOME"), ".cache/")
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), "vgrep-cache/")
 	}
+	return filepath.Join(os.Getenv("HOME"), ".cache/")
+}
+
+// cacheScope returns the subdirectories of the current cache and its lock
+// relative to cacheDir() and lockDir().  Both are empty for the global cache.
+func (v *vgrep) cacheScope() (string, string) {
+	switch {
+	case v.Session != "":
+		return filepath.Join("vgrep-sessions", v.Session), filepath.Join("sessions", v.Session)
+	case v.project != "":
+		return filepath.Join("vgrep-projects", v.project), filepath.Join("projects", v.project)
+	}
+	return "", ""
+}
+
+// cachePath returns the path to the user-specific vgrep cache, which is the
+// cache of the session or, if there's none, of the project unless --global is
+// specified.
+func (v *vgrep) cachePath() (string, error) {
+	cache := cacheDir()
 	exists := true
 
 	if _, err := os.Stat(cache); err != nil {
@@ -507,6 +643,13 @@ func (v *vgrep) cachePath() (string, error) {
 		}
 	}
 
+	if scope, _ := v.cacheScope(); scope != "" {
+		cache = filepath.Join(cache, scope)
+		if err := os.MkdirAll(cache, 0700); err != nil {
+			return "", err
+		}
+	}
+
 	return filepath.Join(cache, "vgrep-go"), nil
 }
 
@@ -514,22 +657,24 @@ func (v *vgrep) cachePath() (string, error) {
 func (v *vgrep) cacheWrite() {
 	go func() {
 		defer v.waiter.Done()
-		if err := v.cacheWriterHelper(); err != nil {
+		if err := v.cacheWriterHelper(true); err != nil {
 			logrus.Debugf("error writing cache: %v", err)
 		}
 	}()
 }
 
-// cacheWriterHelper writes to the user-specific vgrep cache.
-func (v *vgrep) cacheWriterHelper() error {
+// updateCache writes the current matches to the user-specific vgrep cache
+// without adding them to the search history.
+func (v *vgrep) updateCache() error {
+	return v.cacheWriterHelper(false)
+}
+
+// cacheWriterHelper writes to the user-specific vgrep cache and adds the
+// matches to the search history if history is set.
+func (v *vgrep) cacheWriterHelper(history bool) error {
 	logrus.Debug("cacheWriterHelper(): start")
 	defer logrus.Debug("cacheWriterHelper(): end")
 
-	workDir, err := resolvedWorkdir()
-	if err != nil {
-		return err
-	}
-
 	cache, err := v.cachePath()
 	if err != nil {
 		return fmt.Errorf("error getting cache path: %v", err)
@@ -544,19 +689,37 @@ func (v *vgrep) cacheWriterHelper() error {
 		}
 	}()
 
-	file, err := os.OpenFile(cache, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	b, err := v.encodeCache()
 	if err != nil {
 		return err
 	}
 
-	out := append(v.matches, []string{workDir})
+	// Never leave a partially written cache behind.
+	if err := writeFileAtomic(cache, b, 0644); err != nil {
+		return err
+	}
 
-	b, err := json.Marshal(out)
+	if !history {
+		return nil
+	}
+	return v.pushHistory(b)
+}
+
+// writeFileAtomic writes data to the file at path.  The data is written to a
+// temporary file in the same directory first which then replaces the file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	if _, err := file.Write(b); err != nil {
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+
+	if err := file.Chmod(perm); err != nil {
 		return err
 	}
 
@@ -564,7 +727,11 @@ func (v *vgrep) cacheWriterHelper() error {
 		return err
 	}
 
-	return file.Close()
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
 }
 
 // resolvedWorkdir returns the path to current working directory (fully evaluated in case it's a symlink).
@@ -595,35 +762,30 @@ func (v *vgrep) loadCache() error {
 		}
 	}()
 
-	file, err := os.ReadFile(cache)
+	info, err := os.Stat(cache)
 	if err != nil {
 		return err
 	}
-
-	if err := json.Unmarshal(file, &v.matches); err != nil {
-		// if there's an error unmarshalling it, remove the cache file
-		os.Remove(cache)
+	file, err := os.ReadFile(cache)
+	if err != nil {
 		return err
 	}
 
-	if length := len(v.matches); length > 0 {
-		v.workDir = v.matches[length-1][0]
-		v.matches = v.matches[:len(v.matches)-1]
+	data, err := decodeCache(file, info.ModTime())
+	if err != nil {
+		// Move the corrupted cache out of the way but keep it for
+		// inspection.
+		corrupted := cache + ".corrupted"
+		if err := os.Rename(cache, corrupted); err != nil {
+			logrus.Debugf("error moving corrupted cache: %v", err)
+		}
+		return fmt.Errorf("corrupted cache (moved to %s): %v", corrupted, err)
 	}
+	v.useCache(data)
 
 	return nil
 }
 
-// sortKeys returns a sorted []string of m's keys.
-func sortKeys(m map[string]int) []string {
-	keys := make([]string, 0, len(m))
-	for key := range m {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	return keys
-}
-
 // shellCompleter is a completion function for the interactive shell's prompt.
 func shellCompleter(line string) (c []string) {
 	args, err := shellwords.Parse(line)
@@ -676,6 +838,11 @@ func (v *vgrep) commandParse() {
 	defer line.Close()
 	line.SetCtrlCAborts(true)
 	line.SetCompleter(shellCompleter)
+	v.prompt = line
+	defer func() { v.prompt = nil }()
+	if v.Interactive {
+		v.readShellHistory(line)
+	}
 
 	nextInput := func() string {
 		usrInp, err := line.Prompt("Enter a vgrep command: ")
@@ -686,6 +853,7 @@ func (v *vgrep) commandParse() {
 			os.Exit(1)
 		}
 		line.AppendHistory(usrInp)
+		v.writeShellHistory(line)
 		logrus.Debugf("user input: %q", usrInp)
 		return usrInp
 	}
@@ -701,6 +869,53 @@ func (v *vgrep) commandParse() {
 	}
 }
 
+// shellHistoryPath returns the path to the history of the interactive shell,
+// which is kept next to the cache.
+func (v *vgrep) shellHistoryPath() (string, error) {
+	cache, err := v.cachePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(cache), "vgrep-shell-history"), nil
+}
+
+// readShellHistory loads the history of the interactive shell into line.
+func (v *vgrep) readShellHistory(line *liner.State) {
+	path, err := v.shellHistoryPath()
+	if err != nil {
+		logrus.Debugf("error getting shell history path: %v", err)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Debugf("error opening shell history: %v", err)
+		}
+		return
+	}
+	defer file.Close()
+	if _, err := line.ReadHistory(file); err != nil {
+		logrus.Debugf("error reading shell history: %v", err)
+	}
+}
+
+// writeShellHistory writes the history of the interactive shell in line.
+func (v *vgrep) writeShellHistory(line *liner.State) {
+	path, err := v.shellHistoryPath()
+	if err != nil {
+		logrus.Debugf("error getting shell history path: %v", err)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := line.WriteHistory(&buf); err != nil {
+		logrus.Debugf("error encoding shell history: %v", err)
+		return
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0600); err != nil {
+		logrus.Debugf("error writing shell history: %v", err)
+	}
+}
+
 // v.checkIndices is a helper function to fill indices in case it's an empty
 // array and does some range checks otherwise.
 func (v *vgrep) checkIndices(indices []int) ([]int, error) {
@@ -738,7 +953,15 @@ func (v *vgrep) dispatchCommand(input string) bool {
 			fmt.Println("refine expects a regexp argument")
 			return false
 		}
-		return v.commandRefine(cmdArray[1])
+		return v.recordStep(input, func() bool { return v.commandRefine(cmdArray[1]) })
+	}
+
+	if cmdArray[0] == "sort" {
+		if len(cmdArray) != 2 {
+			fmt.Println("sort expects keys to sort by")
+			return false
+		}
+		return v.recordStep(input, func() bool { return v.commandSort(cmdArray[1]) })
 	}
 
 	if cmdArray[0] == "g" || cmdArray[0] == "grep" {
@@ -746,39 +969,69 @@ func (v *vgrep) dispatchCommand(input string) bool {
 			fmt.Println("grep expects at least a pattern")
 			return false
 		}
-		return v.commandGrep(cmdArray[1])
+		return v.recordStep(input, func() bool { return v.commandGrep(cmdArray[1]) })
+	}
+
+	if input == "undo list" || input == "u list" {
+		return v.commandUndoList()
+	}
+
+	if isSessionCommand(input) {
+		return v.commandSession(strings.TrimPrefix(input, "session"))
+	}
+
+	if isReplaceCommand(input) {
+		if cmdArray[0] == "replace" {
+			return v.commandReplace(cmdArray[1])
+		}
+		return v.commandReplace(input[1:])
 	}
 
 	// normalize selector-only inputs (e.g., "1,2,3,5-10") to the show cmd
-	selectorRegexp := `(\s*all|[\d , -]+){0,1}`
-	numRgx := regexp.MustCompile(`^` + selectorRegexp + `$`)
-	if numRgx.MatchString(input) {
+	if selectorOnlyRegexp.MatchString(input) {
 		input = "s " + input
 	}
 
-	cmdRgx := regexp.MustCompile(`^([a-z?]{1,})([\d]+){0,1}` + selectorRegexp + `$`)
+	cmdRgx := regexp.MustCompile(`^([a-z?]{1,})([\d]+(?:,[\d]+)?){0,1}(\s.*){0,1}$`)
 	if !cmdRgx.MatchString(input) {
 		fmt.Printf("%q doesn't match format %q\n", input, "command[context lines] [selectors]")
 		return false
 	}
 
 	var command, selectors string
-	var context int
+	var context, after int
 
 	cmdArray = cmdRgx.FindStringSubmatch(input)
 	command = cmdArray[1]
 	selectors = cmdArray[3]
 	context = -1
 
+	// The context lines are either one number for the lines before and
+	// after the matches or two comma-separated ones (e.g., "c2,15").
 	if len(cmdArray[2]) > 0 {
 		var err error
-		context, err = strconv.Atoi(cmdArray[2])
+		numbers := strings.SplitN(cmdArray[2], ",", 2)
+		context, err = strconv.Atoi(numbers[0])
+		after = context
+		if err == nil && len(numbers) == 2 {
+			after, err = strconv.Atoi(numbers[1])
+		}
 		if err != nil {
 			fmt.Printf("cannot convert specified context lines %q: %v", cmdArray[2], err)
 			return false
 		}
 	}
 
+	// The argument of load is an index of the history, not of the matches.
+	if command == "l" || command == "load" {
+		index, err := strconv.Atoi(strings.TrimSpace(selectors))
+		if err != nil {
+			fmt.Println("load requires exactly one index of the history")
+			return false
+		}
+		return v.commandLoad(index)
+	}
+
 	indices, err := v.parseSelectors(selectors)
 	if err != nil {
 		fmt.Println(err)
@@ -791,33 +1044,51 @@ func (v *vgrep) dispatchCommand(input string) bool {
 
 	case "c", "context":
 		if context == -1 {
-			context = 5
+			context, after = 5, 5
 		}
-		return v.commandPrintContextLines(indices, context)
+		return v.commandPrintContextLines(indices, context, after)
 
 	case "d", "delete":
 		if len(indices) == 0 {
 			fmt.Println("delete requires specified selectors")
 			return false
 		}
-		return v.commandDelete(indices)
+		return v.recordStep(input, func() bool { return v.commandDelete(indices) })
 
 	case "k", "keep":
 		if len(indices) == 0 {
 			fmt.Println("keep requires specified selectors")
 			return false
 		}
-		return v.commandKeep(indices)
+		return v.recordStep(input, func() bool { return v.commandKeep(indices) })
+
+	case "e", "edit":
+		return v.commandEdit(indices)
 
 	case "f", "files":
-		return v.commandListFiles(indices)
+		if context == -1 {
+			context = 0
+		}
+		return v.commandListFiles(indices, context)
+
+	case "h", "history":
+		return v.commandHistory()
 
 	case "p", "print":
 		return v.commandPrintMatches(indices)
 
+	case "ph", "heading":
+		return v.commandPrintGrouped(indices)
+
 	case "q", "quit":
 		return true
 
+	case "redo":
+		return v.commandRedo()
+
+	case "rerun":
+		return v.commandRerun()
+
 	case "s", "show":
 		if len(indices) == 0 {
 			fmt.Println("show requires specified selectors")
@@ -829,7 +1100,13 @@ func (v *vgrep) dispatchCommand(input string) bool {
 		return false
 
 	case "t", "tree":
-		return v.commandListTree(indices)
+		if context == -1 {
+			context = v.Depth
+		}
+		return v.commandListTree(indices, context)
+
+	case "u", "undo":
+		return v.commandUndo()
 
 	default:
 		fmt.Printf("unsupported command %q\n", command)
@@ -842,12 +1119,24 @@ func (v *vgrep) commandPrintHelp() bool {
 	// Join command names, but write first letter in bold.
 	commandList := ansi.Bold(string(commands[0][0])) + commands[0][1:]
 	for _, c := range commands[1:] {
+		if shortcut, exists := shortcuts[c]; exists {
+			if shortcut == "" {
+				commandList += ", " + c
+			} else {
+				commandList += ", " + c + " (" + ansi.Bold(shortcut) + ")"
+			}
+			continue
+		}
 		commandList += ", " + ansi.Bold(string(c[0])) + c[1:]
 	}
 
 	fmt.Printf("vgrep command help: command[context lines] [selectors]\n")
-	fmt.Printf("         selectors: '3' (single), '1,2,6' (multi), '1-8' (range), 'all'\n")
+	fmt.Printf("         selectors: '3' (single), '1,2,6' (multi), '1-8' (range), '10-', '-5' (open range), '0-100:10' (step), 'last', '$', 'all', 'f:*.go', 'd:dir/', '@3' (same file), '/regexp/', 'file~regexp', '!3' (exclude), '&', '|', ' - ' (set operations)\n")
 	fmt.Printf("          commands: %s\n", commandList)
+	fmt.Printf("           replace: 'x/regexp/replacement/ [selectors]'\n")
+	fmt.Printf("              sort: 'sort KEY[:desc][,KEY...]' with keys %s\n", strings.Join(sortKeyNames, ", "))
+	fmt.Printf("           session: 'session [list | rename OLD NEW | copy FROM TO | delete NAME]'\n")
+	fmt.Printf("              undo: 'undo', 'undo list'\n")
 	return false
 }
 
@@ -863,6 +1152,7 @@ func (v *vgrep) commandPrintMatches(indices []int) bool {
 		fmt.Printf("%v\n", err)
 		return false
 	}
+	v.refreshMatches(indices)
 
 	if v.FilesOnly {
 		visited := make(map[string]bool)
@@ -877,23 +1167,15 @@ func (v *vgrep) commandPrintMatches(indices []int) bool {
 		return false
 	}
 
-	if !v.NoHeader {
-		toPrint = append(toPrint, []string{"Index", "File", "Line", "Content"})
+	if v.Heading {
+		return v.commandPrintGrouped(indices)
 	}
 
-	inIDE := isVscode() || isGoland()
+	if !v.NoHeader {
+		toPrint = append(toPrint, v.matchHeader())
+	}
 	for _, i := range indices {
-		switch {
-		case inIDE:
-			// If we're running inside an IDE's terminal, append
-			// the line to the file path, so we can quick jump to
-			// the specific location.  Note that dancing around
-			// with the indexes below is intentional - ugly but
-			// fast.
-			toPrint = append(toPrint, []string{v.matches[i][0], v.matches[i][1] + ":" + v.matches[i][2], v.matches[i][2], v.matches[i][3]})
-		default:
-			toPrint = append(toPrint, v.matches[i])
-		}
+		toPrint = append(toPrint, v.matchRow(i))
 	}
 
 	useLess := !v.NoLess
@@ -901,13 +1183,7 @@ func (v *vgrep) commandPrintMatches(indices []int) bool {
 		useLess = false
 	}
 
-	cw := colwriter.New(4)
-	cw.Headers = true && !v.NoHeader
-	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.BLUE, ansi.GREEN, ansi.DEFAULT}
-	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadRight, colwriter.PadLeft, colwriter.PadNone}
-	cw.UseLess = useLess
-	cw.Trim = []bool{false, false, false, true}
-
+	cw := v.matchWriter(useLess)
 	cw.Open()
 	cw.Write(toPrint)
 	cw.Close()
@@ -915,6 +1191,150 @@ func (v *vgrep) commandPrintMatches(indices []int) bool {
 	return false
 }
 
+// commandPrintGrouped prints the matches specified in indices grouped by
+// their files.  Each file is printed once as a header along with its number
+// of matches, followed by its matches.  If indices is empty all matches are
+// printed.
+func (v *vgrep) commandPrintGrouped(indices []int) bool {
+	var err error
+
+	indices, err = v.checkIndices(indices)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return false
+	}
+	v.refreshMatches(indices)
+
+	// Files are printed in the order of their first match.
+	var files []string
+	rows := make(map[string][][]string)
+	for _, i := range indices {
+		file := v.matches[i][1]
+		if _, exists := rows[file]; !exists {
+			files = append(files, file)
+		}
+		// The file is printed in the header of the section.
+		row := v.matchRow(i)
+		rows[file] = append(rows[file], append([]string{row[0]}, row[2:]...))
+	}
+
+	sections := make([]colwriter.Section, len(files))
+	for i, file := range files {
+		header := ansi.Bold(ansi.Color(file, ansi.BLUE, true))
+		if num := len(rows[file]); num == 1 {
+			header += " (1 match)"
+		} else {
+			header += fmt.Sprintf(" (%d matches)", num)
+		}
+		sections[i] = colwriter.Section{Header: header, Rows: rows[file]}
+	}
+
+	useLess := !v.NoLess && term.IsTerminal(int(os.Stdout.Fd()))
+	cw := v.matchWriter(useLess)
+	cw.Size = slices.Delete(cw.Size, 1, 2)
+	cw.Colors = slices.Delete(cw.Colors, 1, 2)
+	cw.Padding = slices.Delete(cw.Padding, 1, 2)
+	cw.Trim = slices.Delete(cw.Trim, 1, 2)
+
+	var header []string
+	if !v.NoHeader {
+		header = slices.Delete(v.matchHeader(), 1, 2)
+	}
+
+	cw.Open()
+	cw.WriteSections(header, sections)
+	cw.Close()
+
+	return false
+}
+
+// matchHeader returns the header for printing matches.
+func (v *vgrep) matchHeader() []string {
+	if v.Column {
+		return []string{"Index", "File", "Line", "Column", "Content"}
+	}
+	return []string{"Index", "File", "Line", "Content"}
+}
+
+// matchRow returns the row for printing the match at the specified index.
+func (v *vgrep) matchRow(index int) []string {
+	return v.formatMatch(v.matches[index])
+}
+
+// formatMatch returns the row for printing the match m.
+func (v *vgrep) formatMatch(m []string) []string {
+	row := m[:4]
+	if isVscode() || isGoland() {
+		// If we're running inside an IDE's terminal, append
+		// the line (and column) to the file path, so we can
+		// quick jump to the specific location.
+		location := m[1] + ":" + m[2]
+		if column := columnOf(m); column > 0 {
+			location += ":" + strconv.Itoa(column)
+		}
+		row = []string{m[0], location, m[2], m[3]}
+	}
+	if isLost(m) {
+		// Copy the row to leave the match untouched.
+		row = []string{row[0], row[1], row[2], lostLabel + " " + row[3]}
+	}
+	if v.Column {
+		column := ""
+		if c := columnOf(m); c > 0 {
+			column = strconv.Itoa(c)
+		}
+		row = []string{row[0], row[1], row[2], column, row[3]}
+	}
+	return row
+}
+
+// matchWriter returns a colwriter for printing matches.
+func (v *vgrep) matchWriter(useLess bool) *colwriter.ColWriter {
+	colors := []ansi.COLOR{ansi.MAGENTA, ansi.BLUE, ansi.GREEN, ansi.DEFAULT}
+	padding := []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadRight, colwriter.PadLeft, colwriter.PadNone}
+	if v.Column {
+		colors = []ansi.COLOR{ansi.MAGENTA, ansi.BLUE, ansi.GREEN, ansi.GREEN, ansi.DEFAULT}
+		padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadRight, colwriter.PadLeft, colwriter.PadLeft, colwriter.PadNone}
+	}
+
+	cw := colwriter.New(len(colors))
+	cw.Headers = true && !v.NoHeader
+	cw.Colors = colors
+	cw.Padding = padding
+	cw.UseLess = useLess
+	cw.Trim[len(colors)-1] = true
+	return cw
+}
+
+// matchColumn returns the column of the first match at the specified index or
+// 0 if it is unknown (e.g., for context lines or caches of older versions).
+func (v *vgrep) matchColumn(index int) int {
+	return columnOf(v.matches[index])
+}
+
+// matchCharColumn returns the column of the first match at the specified
+// index in characters instead of bytes or 0 if it is unknown.
+func (v *vgrep) matchCharColumn(index int) int {
+	column := v.matchColumn(index)
+	content := ansi.RemoveANSI(v.matches[index][3])
+	if column < 1 || column-1 > len(content) {
+		return column
+	}
+	return utf8.RuneCountInString(content[:column-1]) + 1
+}
+
+// columnOf returns the column of the first match in m or 0 if it is unknown.
+func columnOf(m []string) int {
+	if len(m) < 5 {
+		return 0
+	}
+	column, err := strconv.Atoi(m[4])
+	if err != nil {
+		return 0
+	}
+	return column
+}
+
 // fileLocation returns the path and line number of the matches at the
 // specified index.
 func (v *vgrep) fileLocation(index int) (string, int, error) {
@@ -933,17 +1353,63 @@ func (v *vgrep) fileLocation(index int) (string, int, error) {
 	return p, line, nil
 }
 
-// getContextLines return numLines context lines before and after the match at
-// the specified index including the matched line itself as []string.
-func (v *vgrep) getContextLines(index int, numLines int) [][]string {
-	var contextLines [][]string
+// contextBlock is a window of lines in a file around one or more matches.
+type contextBlock struct {
+	indices []int      // indices of the matches in the block
+	first   int        // first line of the block
+	last    int        // last line of the block
+	rows    [][]string // index label, line number and content of each line
+}
+
+// contextBlocks returns the blocks of before context lines before and after
+// context lines after the matches at the specified indices, which must be
+// matches of the same file.  Overlapping and adjacent windows are merged into
+// one block, in which matched lines are labelled with their indices.  The file
+// is read once.  Lost matches must not be passed since their lines are not
+// known anymore.
+func (v *vgrep) contextBlocks(indices []int, before, after int) []*contextBlock {
+	type window struct {
+		index int
+		line  int
+	}
+	windows := make([]window, 0, len(indices))
+	for _, idx := range indices {
+		line, err := strconv.Atoi(v.matches[idx][2])
+		if err != nil {
+			logrus.Warn(err.Error())
+			continue
+		}
+		windows = append(windows, window{index: idx, line: line})
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].line < windows[j].line })
 
-	path, line, err := v.fileLocation(index)
+	var blocks []*contextBlock
+	labels := make(map[int][]int) // line -> indices of matches
+	for _, w := range windows {
+		first, last := w.line-before, w.line+after
+		if first < 1 {
+			first = 1
+		}
+		if n := len(blocks); n > 0 && first <= blocks[n-1].last+1 {
+			if last > blocks[n-1].last {
+				blocks[n-1].last = last
+			}
+		} else {
+			blocks = append(blocks, &contextBlock{first: first, last: last})
+		}
+		block := blocks[len(blocks)-1]
+		block.indices = append(block.indices, w.index)
+		labels[w.line] = append(labels[w.line], w.index)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	path, _, err := v.fileLocation(indices[0])
 	if err != nil {
 		logrus.Warn(err.Error())
 		return nil
 	}
-
 	file, err := os.Open(path)
 	if err != nil {
 		logrus.Warnf("error opening file %q: %v", path, err)
@@ -953,67 +1419,134 @@ func (v *vgrep) getContextLines(index int, numLines int) [][]string {
 
 	scanner := bufio.NewScanner(file)
 	counter := 0
-	for scanner.Scan() {
+	current := 0
+	for current < len(blocks) && scanner.Scan() {
 		counter++
-		if counter == line {
-			newContext := []string{strconv.Itoa(counter), v.matches[index][3]}
-			contextLines = append(contextLines, newContext)
-		} else if (counter >= line-numLines) && (counter <= line+numLines) {
-			newContext := []string{strconv.Itoa(counter), scanner.Text()}
-			contextLines = append(contextLines, newContext)
-		}
-		if counter > line+numLines {
-			break
+		block := blocks[current]
+		if counter < block.first {
+			continue
+		}
+		label, content := "", scanner.Text()
+		if matched := labels[counter]; len(matched) > 0 {
+			label = formatIndices(matched)
+			content = v.matches[matched[0]][3]
+		}
+		block.rows = append(block.rows, []string{label, strconv.Itoa(counter), content})
+		if counter == block.last {
+			current++
 		}
 	}
 
-	return contextLines
+	return blocks
+}
+
+// formatIndices returns the sorted indices as a comma-separated list, in
+// which consecutive indices are collapsed into ranges (e.g., "1,3-5").
+func formatIndices(indices []int) string {
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		part := strconv.Itoa(sorted[i])
+		if j > i {
+			part += "-" + strconv.Itoa(sorted[j])
+		}
+		parts = append(parts, part)
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
 }
 
-// commandPrintContextLines prints at most numLines context lines before and
-// after each match specified in indices.
-func (v *vgrep) commandPrintContextLines(indices []int, numLines int) bool {
+// commandPrintContextLines prints at most before context lines before and
+// after context lines after each match specified in indices.  The matches are
+// grouped by their files, and overlapping context windows are merged.  Lost
+// matches are listed after the context of their file.
+func (v *vgrep) commandPrintContextLines(indices []int, before, after int) bool {
 	var err error
 
-	logrus.Debugf("commandPrintContextLines(indices=[..], numlines=%d)", numLines)
+	logrus.Debugf("commandPrintContextLines(indices=[..], before=%d, after=%d)", before, after)
 	indices, err = v.checkIndices(indices)
 	if err != nil {
 		fmt.Printf("%v\n", err)
 		return false
 	}
+	v.refreshMatches(indices)
 
-	cw := colwriter.New(2)
-	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.DEFAULT}
-	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadNone}
-	cw.UseLess = !v.NoLess
-	cw.Open()
-
+	// Files are printed in the order of their first match.
+	var files []string
+	byFile := make(map[string][]int)
+	lostByFile := make(map[string][]int)
 	for _, idx := range indices {
-		toPrint := v.getContextLines(idx, numLines)
-		if toPrint == nil {
+		file := v.matches[idx][1]
+		if _, exists := byFile[file]; !exists {
+			files = append(files, file)
+			byFile[file] = nil
+		}
+		if v.isLost(idx) {
+			lostByFile[file] = append(lostByFile[file], idx)
 			continue
 		}
+		byFile[file] = append(byFile[file], idx)
+	}
+
+	cw := colwriter.New(3)
+	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.GREEN, ansi.DEFAULT}
+	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadLeft, colwriter.PadNone}
+	cw.UseLess = !v.NoLess
+	cw.Open()
 
-		sep := fmt.Sprintf("%s %s %s ",
-			ansi.Color("---", ansi.MAGENTA, false),
-			ansi.Color(strconv.Itoa(idx), ansi.MAGENTA, false),
-			ansi.Color(v.matches[idx][1], ansi.BLUE, false))
-		for i := 0; i < 80-len(ansi.RemoveANSI(sep)); i++ {
-			sep += ansi.Color("---", ansi.MAGENTA, false)
+	for _, file := range files {
+		for _, block := range v.contextBlocks(byFile[file], before, after) {
+			if len(block.rows) == 0 {
+				continue
+			}
+			cw.WriteString(contextSeparator(block.indices, file, false))
+			cw.Write(block.rows)
+		}
+		if lost := lostByFile[file]; len(lost) > 0 {
+			cw.WriteString(contextSeparator(lost, file, true))
+			for _, idx := range lost {
+				m := v.matches[idx]
+				cw.Write([][]string{{m[0], m[2], m[3]}})
+			}
 		}
-		sep += "\n"
-		cw.WriteString(sep)
-		cw.Write(toPrint)
 	}
 
 	cw.Close()
 	return false
 }
 
-// commandDelete deletes all indices from v.matches. It updates all other indices.
+// contextSeparator returns the line separating the context of the matches at
+// the specified indices of file from previous output.
+func contextSeparator(indices []int, file string, lost bool) string {
+	sep := fmt.Sprintf("%s %s %s ",
+		ansi.Color("---", ansi.MAGENTA, false),
+		ansi.Color(formatIndices(indices), ansi.MAGENTA, false),
+		ansi.Color(file, ansi.BLUE, false))
+	if lost {
+		sep += lostLabel + " "
+	}
+	for i := 0; i < 80-len(ansi.RemoveANSI(sep)); i++ {
+		sep += ansi.Color("---", ansi.MAGENTA, false)
+	}
+	return sep + "\n"
+}
+
+// commandDelete deletes all indices from v.matches. It updates all other
+// indices and writes the remaining matches to the cache.
 func (v *vgrep) commandDelete(indices []int) bool {
 	var err error
 
+	// Nothing to delete (e.g., when refining with a pattern that all
+	// matches match).  Do not let checkIndices select all matches.
+	if len(indices) == 0 {
+		return false
+	}
+
 	indices, err = v.checkIndices(indices)
 	if err != nil {
 		fmt.Printf("%v\n", err)
@@ -1029,6 +1562,9 @@ func (v *vgrep) commandDelete(indices []int) bool {
 		v.matches = append(v.matches[:index], v.matches[index+1:]...)
 	}
 
+	if err := v.updateCache(); err != nil {
+		fmt.Printf("error writing cache: %v\n", err)
+	}
 	return false
 }
 
@@ -1076,14 +1612,7 @@ func (v *vgrep) commandGrep(expr string) bool {
 	}
 
 	logrus.Debugf("new grep from interactive shell, passed args: %s", args)
-	v.waiter.Add(1)
-	v.grep(args)
-	v.cacheWrite()
-
-	if len(v.matches) > 0 {
-		v.commandPrintMatches([]int{})
-	}
-
+	v.search(args) // return to the prompt when cancelled
 	v.waiter.Wait()
 	return false
 }
@@ -1094,6 +1623,11 @@ func (v *vgrep) commandShow(index int) bool {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return false
 	}
+	v.refreshMatches([]int{index})
+	if v.isLost(index) {
+		fmt.Printf("couldn't open index %d: match not found in %s anymore\n", index, v.matches[index][1])
+		return false
+	}
 
 	editor := v.getEditor()
 	path, line, err := v.fileLocation(index)
@@ -1115,12 +1649,34 @@ func (v *vgrep) commandShow(index int) bool {
 
 	lFlag := fmt.Sprintf("%s%d", v.getEditorLineFlag(), line)
 
+	// Open the editor at the exact column if it's known and supported
+	// by the editor.  A custom EDITORLINEFLAG takes precedence.
+	var posArgs []string
+	if column := v.matchColumn(index); column > 0 && os.Getenv("EDITORLINEFLAG") == "" {
+		_, file := filepath.Split(editor[0])
+		// vim's cursor() expects a byte offset while the other editors
+		// expect the number of characters.
+		switch file {
+		case "vi", "vim", "nvim", "gvim":
+			lFlag = fmt.Sprintf("+call cursor(%d,%d)", line, column)
+		case "emacs", "emacsclient":
+			lFlag = fmt.Sprintf("+%d:%d", line, v.matchCharColumn(index))
+		case "nano":
+			lFlag = fmt.Sprintf("+%d,%d", line, v.matchCharColumn(index))
+		case "code", "code-insiders", "codium":
+			posArgs = []string{"-g", fmt.Sprintf("%s:%d:%d", path, line, v.matchCharColumn(index))}
+		}
+	}
+
 	logrus.Debugf("opening index %d via: %s %s %s", index, editor, path, lFlag)
 
 	var cmd *exec.Cmd
-	if lFlag_reversed {
+	switch {
+	case posArgs != nil:
+		editor = append(editor, posArgs...)
+	case lFlag_reversed:
 		editor = append(editor, lFlag, path)
-	} else {
+	default:
 		editor = append(editor, path, lFlag)
 	}
 	cmd = exec.Command(editor[0], editor[1:]...)
@@ -1134,156 +1690,3 @@ func (v *vgrep) commandShow(index int) bool {
 
 	return false
 }
-
-// commandListTree prints statistics about how many matches occur in which
-// directories in the search.
-func (v *vgrep) commandListTree(indices []int) bool {
-	var err error
-
-	indices, err = v.checkIndices(indices)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		return false
-	}
-
-	count := make(map[string]int)
-	for _, idx := range indices {
-		m := v.matches[idx]
-		split := strings.Split(m[1], "/")
-		if len(split) == 1 {
-			count["."]++
-			continue
-		}
-		for i := range split {
-			path := strings.Join(split[:i], "/")
-			count[path]++
-		}
-	}
-
-	var toPrint [][]string
-	if !v.NoHeader {
-		toPrint = append(toPrint, []string{"Matches", "Directory"})
-	}
-
-	for _, k := range sortKeys(count) {
-		num := strconv.Itoa(count[k])
-		toPrint = append(toPrint, []string{num, k})
-	}
-
-	cw := colwriter.New(2)
-	cw.Headers = true && !v.NoHeader
-	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.GREEN}
-	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadNone}
-	cw.UseLess = !v.NoLess
-
-	cw.Open()
-	cw.Write(toPrint)
-	cw.Close()
-
-	return false
-}
-
-// commandListFiles prints statistics about how many matches occur in which
-// files in the search.
-func (v *vgrep) commandListFiles(indices []int) bool {
-	var err error
-
-	if indices, err = v.checkIndices(indices); err != nil {
-		fmt.Printf("%v\n", err)
-		return false
-	}
-
-	count := make(map[string]int)
-	for _, idx := range indices {
-		m := v.matches[idx]
-		count[m[1]]++
-	}
-
-	var toPrint [][]string
-	if !v.NoHeader {
-		toPrint = append(toPrint, []string{"Matches", "File"})
-	}
-
-	for _, k := range sortKeys(count) {
-		num := strconv.Itoa(count[k])
-		toPrint = append(toPrint, []string{num, k})
-	}
-
-	cw := colwriter.New(2)
-	cw.Headers = true && !v.NoHeader
-	cw.Colors = []ansi.COLOR{ansi.MAGENTA, ansi.GREEN}
-	cw.Padding = []colwriter.PaddingFunc{colwriter.PadLeft, colwriter.PadNone}
-	cw.UseLess = !v.NoLess
-
-	cw.Open()
-	cw.Write(toPrint)
-	cw.Close()
-
-	return false
-}
-
-// parseSelectors parses input for vgrep selectors and returns the corresponding
-// indices as a sorted []int.
-func (v *vgrep) parseSelectors(input string) ([]int, error) {
-	indices := []int{}
-
-	if strings.TrimSpace(input) == "all" {
-		for i := 0; i < len(v.matches); i++ {
-			indices = append(indices, i)
-		}
-		return indices, nil
-	}
-
-	selRgx := regexp.MustCompile("([^,]+)")
-
-	toInt := func(idx string) (int, error) {
-		idx = strings.TrimSpace(idx)
-		num, err := strconv.Atoi(idx)
-		if err != nil {
-			return -1, fmt.Errorf("non-numeric selector %q", idx)
-		}
-		return num, nil
-	}
-
-	addIndex := func(idx int) {
-		for _, x := range indices {
-			if x == idx {
-				return
-			}
-		}
-		indices = append(indices, idx)
-	}
-
-	for _, sel := range selRgx.FindAllString(input, -1) {
-		rng := strings.Split(sel, "-")
-		if len(rng) == 1 {
-			num, err := toInt(rng[0])
-			if err != nil {
-				return nil, err
-			}
-			addIndex(num)
-		} else if len(rng) == 2 {
-			from, err := toInt(rng[0])
-			if err != nil {
-				return nil, err
-			}
-			to, err := toInt(rng[1])
-			if err != nil {
-				return nil, err
-			}
-			if from > to {
-				cpy := from
-				from = to
-				to = cpy
-			}
-			for i := from; i <= to; i++ {
-				addIndex(i)
-			}
-		} else {
-			return nil, fmt.Errorf("invalid range format %q", sel)
-		}
-	}
-
-	sort.Ints(indices)
-	return indices, nil
-}